@@ -0,0 +1,203 @@
+// Package authors keeps the authors credited on books, and the author
+// fields books carry for display and search, consistent with each other.
+//
+// Books reference their authors by ID in Authors. Author_name and
+// Author_info on a book are caches: the names of the credited authors, and
+// the bio of the first, derived whenever either side changes.
+package authors
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// refreshBatchSize is how many books are rewritten at a time when an author
+// changes.
+const refreshBatchSize = 500
+
+var (
+	ErrNoAuthors   = errors.New("a book needs at least one author")
+	ErrInvalidRole = errors.New("author role must be author, editor, translator or illustrator")
+)
+
+// UnknownAuthorError names an author ID that does not exist.
+type UnknownAuthorError struct {
+	Author_id primitive.ObjectID
+}
+
+func (e *UnknownAuthorError) Error() string {
+	return "author " + e.Author_id.Hex() + " not found"
+}
+
+var authorsCollection *mongo.Collection = database.OpenCollection(database.Client, "authors")
+var booksCollection *mongo.Collection = database.OpenCollection(database.Client, "books")
+
+var roles = map[string]bool{
+	models.AuthorRoleAuthor:      true,
+	models.AuthorRoleEditor:      true,
+	models.AuthorRoleTranslator:  true,
+	models.AuthorRoleIllustrator: true,
+}
+
+// Credit checks the authors credited on a book and returns the credits with
+// current names, along with the Author_name and Author_info caches for the
+// book. A credit without a role is for an author.
+func Credit(ctx context.Context, credits []models.BookAuthor) ([]models.BookAuthor, string, string, error) {
+	if len(credits) == 0 {
+		return nil, "", "", ErrNoAuthors
+	}
+	ids := make([]primitive.ObjectID, len(credits))
+	for i, credit := range credits {
+		ids[i] = credit.Author_id
+	}
+	found, err := load(ctx, ids)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	resolved := make([]models.BookAuthor, len(credits))
+	for i, credit := range credits {
+		if credit.Role == "" {
+			credit.Role = models.AuthorRoleAuthor
+		}
+		if !roles[credit.Role] {
+			return nil, "", "", ErrInvalidRole
+		}
+		author, ok := found[credit.Author_id]
+		if !ok {
+			return nil, "", "", &UnknownAuthorError{Author_id: credit.Author_id}
+		}
+		resolved[i] = models.BookAuthor{Author_id: author.ID, Name: author.Name, Role: credit.Role}
+	}
+	name, info := caches(resolved, found)
+	return resolved, name, info, nil
+}
+
+func load(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]models.Author, error) {
+	cursor, err := authorsCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var authors []models.Author
+	if err := cursor.All(ctx, &authors); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.Author, len(authors))
+	for _, author := range authors {
+		byID[author.ID] = author
+	}
+	return byID, nil
+}
+
+// caches derives a book's Author_name and Author_info from its credits:
+// the names in its byline and the bio of the first of them.
+func caches(credits []models.BookAuthor, authors map[primitive.ObjectID]models.Author) (string, string) {
+	named := models.Byline(credits)
+	names := make([]string, len(named))
+	for i, credit := range named {
+		names[i] = credit.Name
+	}
+	return strings.Join(names, ", "), authors[named[0].Author_id].Bio
+}
+
+// Refresh rewrites the credits and author caches of every book crediting
+// the author with id, after the author was renamed or their bio changed.
+func Refresh(ctx context.Context, id primitive.ObjectID) error {
+	cursor, err := booksCollection.Find(ctx,
+		bson.M{"authors.author_id": id},
+		options.Find().SetProjection(bson.M{"authors": 1}))
+	if err != nil {
+		return err
+	}
+	var books []models.Books
+	if err := cursor.All(ctx, &books); err != nil {
+		return err
+	}
+
+	var ids []primitive.ObjectID
+	for _, book := range books {
+		for _, credit := range book.Authors {
+			ids = append(ids, credit.Author_id)
+		}
+	}
+	found, err := load(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	var writes []mongo.WriteModel
+	for _, book := range books {
+		credits := make([]models.BookAuthor, 0, len(book.Authors))
+		for _, credit := range book.Authors {
+			if author, ok := found[credit.Author_id]; ok {
+				credit.Name = author.Name
+			}
+			credits = append(credits, credit)
+		}
+		name, info := caches(credits, found)
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": book.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"authors": credits, "author_name": name, "author_info": info}}))
+		if len(writes) == refreshBatchSize {
+			if _, err := booksCollection.BulkWrite(ctx, writes); err != nil {
+				return err
+			}
+			writes = writes[:0]
+		}
+	}
+	if len(writes) > 0 {
+		_, err = booksCollection.BulkWrite(ctx, writes)
+	}
+	return err
+}
+
+// IsCredited reports whether any book credits the author with id.
+func IsCredited(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	count, err := booksCollection.CountDocuments(ctx, bson.M{"authors.author_id": id}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// EnsureIndexes creates the index used to find the books crediting an
+// author, and the unique index on author names. Authors that already share
+// a name have to be renamed apart or deleted before the unique index can be
+// built; until then names keep a plain index and a warning is logged on
+// every start.
+func EnsureIndexes(ctx context.Context) error {
+	if _, err := booksCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "authors.author_id", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	// Earlier versions indexed names without making them unique.
+	specs, err := authorsCollection.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if spec.Name == "name_1" && (spec.Unique == nil || !*spec.Unique) {
+			if _, err := authorsCollection.Indexes().DropOne(ctx, spec.Name); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = authorsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		log.Println("author names are not unique yet, rename or delete the duplicates through /admin/author/:author_id:", err)
+		_, err = authorsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "name", Value: 1}},
+		})
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package authors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationReport counts what Migrate did.
+type MigrationReport struct {
+	Authors_created int `json:"authors_created"`
+	Books_linked    int `json:"books_linked"`
+}
+
+// Migrate credits every book that has no Authors yet with an author named
+// by its Author_name, creating the author on first sight with the book's
+// Author_info as bio. Names are matched exactly, after trimming spaces, and
+// are not split into co-authors. Migrate can be run again, for instance
+// after an import, and only touches books it has not linked before.
+func Migrate(ctx context.Context) (MigrationReport, error) {
+	var report MigrationReport
+	cursor, err := booksCollection.Find(ctx,
+		bson.M{
+			"author_name": bson.M{"$gt": ""},
+			"$or":         bson.A{bson.M{"authors": bson.M{"$exists": false}}, bson.M{"authors": bson.M{"$size": 0}}, bson.M{"authors": nil}},
+		},
+		options.Find().SetProjection(bson.M{"author_name": 1, "author_info": 1}))
+	if err != nil {
+		return report, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var book models.Books
+		if err := cursor.Decode(&book); err != nil {
+			return report, err
+		}
+		name := strings.TrimSpace(book.Author_name)
+		if name == "" {
+			continue
+		}
+
+		author, created, err := findOrCreate(ctx, name, book.Author_info)
+		if err != nil {
+			return report, err
+		}
+		if created {
+			report.Authors_created++
+		}
+
+		credits := []models.BookAuthor{{Author_id: author.ID, Name: author.Name, Role: models.AuthorRoleAuthor}}
+		_, err = booksCollection.UpdateOne(ctx,
+			bson.M{"_id": book.ID},
+			bson.M{"$set": bson.M{"authors": credits, "author_name": author.Name, "author_info": author.Bio}})
+		if err != nil {
+			return report, err
+		}
+		report.Books_linked++
+	}
+	return report, cursor.Err()
+}
+
+// Resolve returns the author called name, creating them with bio on first
+// sight the way Migrate does, for importers that link books as they write
+// them.
+func Resolve(ctx context.Context, name, bio string) (models.Author, error) {
+	author, _, err := findOrCreate(ctx, strings.TrimSpace(name), bio)
+	return author, err
+}
+
+// findOrCreate returns the author called name, creating them with bio if
+// there is none. An existing author without a bio takes bio.
+func findOrCreate(ctx context.Context, name, bio string) (models.Author, bool, error) {
+	now := time.Now()
+	result, err := authorsCollection.UpdateOne(ctx,
+		bson.M{"name": name},
+		bson.M{"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "bio": bio, "created_at": now, "updated_at": now}},
+		options.Update().SetUpsert(true),
+	)
+	// A concurrent upsert of the same name trips the unique index; the
+	// author it created is the one wanted.
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return models.Author{}, false, err
+	}
+	created := err == nil && result.UpsertedID != nil
+
+	var author models.Author
+	if err := authorsCollection.FindOne(ctx, bson.M{"name": name}).Decode(&author); err != nil {
+		return author, created, err
+	}
+
+	if author.Bio == "" && bio != "" {
+		_, err := authorsCollection.UpdateOne(ctx,
+			bson.M{"_id": author.ID, "bio": ""},
+			bson.M{"$set": bson.M{"bio": bio, "updated_at": now}})
+		if err != nil {
+			return author, created, err
+		}
+		author.Bio = bio
+	}
+	return author, created, nil
+}
@@ -0,0 +1,53 @@
+package authors
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestFindOrCreate(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	id := primitive.NewObjectID()
+	author := bson.D{{Key: "_id", Value: id}, {Key: "name", Value: "Jane Austen"}, {Key: "bio", Value: "Novelist"}}
+
+	tests := []struct {
+		name        string
+		upsert      bson.D
+		wantCreated bool
+	}{
+		{
+			name: "created",
+			upsert: mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 0},
+				bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 0}, {Key: "_id", Value: id}}}}),
+			wantCreated: true,
+		},
+		{
+			name:   "found",
+			upsert: mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 0}),
+		},
+		{
+			// Another import created the author between the upsert's
+			// lookup and its insert.
+			name:   "created concurrently",
+			upsert: mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}),
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			authorsCollection = mt.Coll
+			mt.AddMockResponses(tt.upsert, mtest.CreateCursorResponse(0, "test.authors", mtest.FirstBatch, author))
+			got, created, err := findOrCreate(ctx, "Jane Austen", "Novelist")
+			if err != nil {
+				mt.Fatal(err)
+			}
+			if got.ID != id || got.Name != "Jane Austen" || created != tt.wantCreated {
+				mt.Errorf("findOrCreate = %+v, created %v, want %s, created %v", got, created, id.Hex(), tt.wantCreated)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package catalog
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportBatchSize is how many books are fetched from Mongo at a time.
+const exportBatchSize = 1000
+
+// ContentTypes gives the media type of each export format.
+var ContentTypes = map[Format]string{
+	CSV:       "text/csv; charset=utf-8",
+	JSONLines: "application/x-ndjson",
+	ONIX:      "application/xml; charset=utf-8",
+}
+
+// Extensions gives the file extension of each export format.
+var Extensions = map[Format]string{
+	CSV:       "csv",
+	JSONLines: "jsonl",
+	ONIX:      "xml",
+}
+
+// Exporter writes the books of a collection out in bulk.
+type Exporter struct {
+	Books *mongo.Collection
+	// Sender and Currency fill the ONIX message header and prices.
+	Sender   string
+	Currency string
+}
+
+// bookWriter writes books in one format.
+type bookWriter interface {
+	begin() error
+	write(book models.Books) error
+	end() error
+}
+
+// Export writes the books matching filter to w in format, ordered by ID, and
+// returns how many were written. Books are streamed from the collection a
+// batch at a time, so exports are not limited by memory.
+func (ex *Exporter) Export(ctx context.Context, w io.Writer, format Format, filter bson.M) (int, error) {
+	buffered := bufio.NewWriter(w)
+	var out bookWriter
+	switch format {
+	case CSV:
+		out = &csvWriter{w: csv.NewWriter(buffered)}
+	case JSONLines:
+		out = &jsonLinesWriter{encoder: json.NewEncoder(buffered)}
+	case ONIX:
+		out = &onixWriter{w: buffered, encoder: xml.NewEncoder(buffered), sender: ex.Sender, currency: ex.Currency}
+	default:
+		return 0, fmt.Errorf("%w: unknown format %q", ErrInvalidInput, format)
+	}
+
+	cursor, err := ex.Books.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(exportBatchSize))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := out.begin(); err != nil {
+		return 0, err
+	}
+	count := 0
+	for cursor.Next(ctx) {
+		var book models.Books
+		if err := cursor.Decode(&book); err != nil {
+			return count, err
+		}
+		if err := out.write(book.WithAvailability()); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	if err := out.end(); err != nil {
+		return count, err
+	}
+	return count, buffered.Flush()
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) begin() error {
+	return cw.w.Write(Columns)
+}
+
+func (cw *csvWriter) write(book models.Books) error {
+	publishedAt := ""
+	if book.Published_at != nil {
+		publishedAt = book.Published_at.Format(time.RFC3339)
+	}
+	return cw.w.Write([]string{
+		book.Isbn_13, book.Isbn_10, book.Name, book.Author_name, formatCredits(book.Authors), book.Author_info, book.Publication,
+		publishedAt, book.Genre, book.Category, book.Description, strconv.Itoa(book.Price), strconv.Itoa(book.Stock),
+	})
+}
+
+func (cw *csvWriter) end() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonLinesWriter struct {
+	encoder *json.Encoder
+}
+
+func (jw *jsonLinesWriter) begin() error { return nil }
+
+func (jw *jsonLinesWriter) write(book models.Books) error {
+	return jw.encoder.Encode(book)
+}
+
+func (jw *jsonLinesWriter) end() error { return nil }
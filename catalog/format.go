@@ -0,0 +1,245 @@
+// Package catalog moves books in and out of the catalogue in bulk.
+package catalog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+)
+
+// Format is a file format books are imported from or exported to.
+type Format string
+
+const (
+	CSV       Format = "csv"
+	JSONLines Format = "jsonl"
+)
+
+// ErrInvalidInput is wrapped by errors about a file as a whole, such as an
+// unknown CSV column, as opposed to errors about a single row.
+var ErrInvalidInput = errors.New("invalid input")
+
+// ParseFormat accepts csv, jsonl and its alias ndjson, and onix.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return CSV, nil
+	case "jsonl", "ndjson":
+		return JSONLines, nil
+	case "onix", "xml":
+		return ONIX, nil
+	}
+	return "", fmt.Errorf("%w: unknown format %q", ErrInvalidInput, s)
+}
+
+// Columns are the CSV columns, named like the JSON fields of models.Books.
+// Imports may give them in any order and leave some out.
+var Columns = []string{
+	"isbn_13", "isbn_10", "name", "author_name", "authors", "author_info", "publication",
+	"published_at", "genre", "category", "description", "price", "stock",
+}
+
+// creditSeparator separates the credits in the authors column. The byline
+// in author_name joins names with commas, which names themselves may
+// contain, so only the authors column is read back as a list.
+const creditSeparator = "; "
+
+// formatCredits writes credits for the authors column, each as the name
+// followed by the role in parentheses unless the role is author.
+func formatCredits(credits []models.BookAuthor) string {
+	formatted := make([]string, len(credits))
+	for i, credit := range credits {
+		formatted[i] = credit.Name
+		if credit.Role != "" && credit.Role != models.AuthorRoleAuthor {
+			formatted[i] += " (" + credit.Role + ")"
+		}
+	}
+	return strings.Join(formatted, creditSeparator)
+}
+
+// parseCredits reads the authors column written by formatCredits.
+func parseCredits(value string) ([]models.BookAuthor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var credits []models.BookAuthor
+	for _, formatted := range strings.Split(value, strings.TrimSpace(creditSeparator)) {
+		credit := models.BookAuthor{Name: strings.TrimSpace(formatted), Role: models.AuthorRoleAuthor}
+		for _, role := range []string{models.AuthorRoleAuthor, models.AuthorRoleEditor, models.AuthorRoleTranslator, models.AuthorRoleIllustrator} {
+			if name, ok := strings.CutSuffix(credit.Name, " ("+role+")"); ok {
+				credit.Name, credit.Role = strings.TrimSpace(name), role
+				break
+			}
+		}
+		if credit.Name == "" {
+			return nil, errors.New("empty author")
+		}
+		credits = append(credits, credit)
+	}
+	return credits, nil
+}
+
+// maxLineSize bounds a single JSON Lines record.
+const maxLineSize = 1 << 20
+
+// record is a decoded row, numbered from 1, or the reason it could not be
+// decoded.
+type record struct {
+	Row  int
+	Book models.Books
+	Err  error
+}
+
+// decode reads books from r and hands them to fn one by one.
+func decode(r io.Reader, format Format, fn func(record) error) error {
+	switch format {
+	case CSV:
+		return decodeCSV(r, fn)
+	case JSONLines:
+		return decodeJSONLines(r, fn)
+	}
+	return fmt.Errorf("%w: books cannot be imported from %s", ErrInvalidInput, format)
+}
+
+func decodeCSV(r io.Reader, fn func(record) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: reading header: %v", ErrInvalidInput, err)
+	}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !isColumn(column) {
+			return fmt.Errorf("%w: unknown column %q", ErrInvalidInput, column)
+		}
+		header[i] = column
+	}
+
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := fn(record{Row: row, Err: parseErr.Err}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(fields) != len(header) {
+			err = fmt.Errorf("expected %d fields, got %d", len(header), len(fields))
+			if err := fn(record{Row: row, Err: err}); err != nil {
+				return err
+			}
+			continue
+		}
+		book, err := bookFromCSV(header, fields)
+		if err := fn(record{Row: row, Book: book, Err: err}); err != nil {
+			return err
+		}
+	}
+}
+
+func isColumn(name string) bool {
+	for _, column := range Columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+func bookFromCSV(header, fields []string) (models.Books, error) {
+	var book models.Books
+	for i, column := range header {
+		value := strings.TrimSpace(fields[i])
+		var err error
+		switch column {
+		case "isbn_13":
+			book.Isbn_13 = value
+		case "isbn_10":
+			book.Isbn_10 = value
+		case "name":
+			book.Name = value
+		case "author_name":
+			book.Author_name = value
+		case "authors":
+			book.Authors, err = parseCredits(value)
+		case "author_info":
+			book.Author_info = value
+		case "publication":
+			book.Publication = value
+		case "published_at":
+			if value != "" {
+				var at time.Time
+				at, err = parseDate(value)
+				book.Published_at = &at
+			}
+		case "genre":
+			book.Genre = value
+		case "category":
+			book.Category = value
+		case "description":
+			book.Description = value
+		case "price":
+			if value != "" {
+				book.Price, err = strconv.Atoi(value)
+			}
+		case "stock":
+			if value != "" {
+				book.Stock, err = strconv.Atoi(value)
+			}
+		}
+		if err != nil {
+			return models.Books{}, fmt.Errorf("invalid %s %q", column, value)
+		}
+	}
+	return book, nil
+}
+
+// parseDate accepts a bare date or an RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func decodeJSONLines(r io.Reader, fn func(record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for row := 1; scanner.Scan(); row++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			row--
+			continue
+		}
+		var book models.Books
+		err := json.Unmarshal([]byte(line), &book)
+		if err := fn(record{Row: row, Book: book, Err: err}); err != nil {
+			return err
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("%w: a line is longer than %d bytes", ErrInvalidInput, maxLineSize)
+	}
+	return scanner.Err()
+}
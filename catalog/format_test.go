@@ -0,0 +1,223 @@
+package catalog
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+)
+
+// decodeAll collects the records decoded from input.
+func decodeAll(input string, format Format) ([]record, error) {
+	var records []record
+	err := decode(strings.NewReader(input), format, func(rec record) error {
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr bool
+	}{
+		{"csv", CSV, false},
+		{"CSV", CSV, false},
+		{"jsonl", JSONLines, false},
+		{"ndjson", JSONLines, false},
+		{"onix", ONIX, false},
+		{"xml", ONIX, false},
+		{"xlsx", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q, error: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("ParseFormat(%q) error %v does not wrap ErrInvalidInput", tt.in, err)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	published := time.Date(1937, 9, 21, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		want   []record
+		// wantRowErr lists the rows expected to fail on their own.
+		wantRowErr []int
+	}{
+		{
+			name:   "csv",
+			format: CSV,
+			input: "isbn_13,name,author_name,published_at,price,stock\n" +
+				"9780306406157,The Hobbit,J. R. R. Tolkien,1937-09-21,1500,3\n",
+			want: []record{{Row: 1, Book: models.Books{
+				Isbn_13: "9780306406157", Name: "The Hobbit", Author_name: "J. R. R. Tolkien",
+				Published_at: &published, Price: 1500, Stock: 3,
+			}}},
+		},
+		{
+			name:   "csv authors column",
+			format: CSV,
+			input: "name,author_name,authors\n" +
+				"Good Omens,\"Terry Pratchett, Neil Gaiman\",Terry Pratchett; Neil Gaiman; Stephen Briggs (illustrator)\n",
+			want: []record{{Row: 1, Book: models.Books{
+				Name: "Good Omens", Author_name: "Terry Pratchett, Neil Gaiman",
+				Authors: []models.BookAuthor{
+					{Name: "Terry Pratchett", Role: models.AuthorRoleAuthor},
+					{Name: "Neil Gaiman", Role: models.AuthorRoleAuthor},
+					{Name: "Stephen Briggs", Role: models.AuthorRoleIllustrator},
+				},
+			}}},
+		},
+		{
+			name:   "csv columns in any order, case and spacing",
+			format: CSV,
+			input:  " Name , ISBN_13\n  Dune ,  9780441013593 \n",
+			want:   []record{{Row: 1, Book: models.Books{Isbn_13: "9780441013593", Name: "Dune"}}},
+		},
+		{
+			name:   "csv RFC 3339 dates and empty numbers",
+			format: CSV,
+			input:  "name,published_at,price,stock\nThe Hobbit,1937-09-21T00:00:00Z,,\n",
+			want:   []record{{Row: 1, Book: models.Books{Name: "The Hobbit", Published_at: &published}}},
+		},
+		{
+			name:   "csv row errors",
+			format: CSV,
+			input: "name,price,published_at\n" +
+				"Dune,12.50,\n" +
+				"Dune,1250,21/09/1937\n" +
+				"Dune,1250\n" +
+				"Emma,900,\n",
+			want:       []record{{Row: 4, Book: models.Books{Name: "Emma", Price: 900}}},
+			wantRowErr: []int{1, 2, 3},
+		},
+		{
+			name:   "csv quoting error",
+			format: CSV,
+			// The unterminated quote swallows the rest of the file.
+			input:      "name\n\"Dune\nEmma\n",
+			wantRowErr: []int{1},
+		},
+		{
+			name:   "empty csv",
+			format: CSV,
+			input:  "",
+		},
+		{
+			name:   "jsonl",
+			format: JSONLines,
+			input: `{"isbn_13":"9780306406157","name":"The Hobbit","price":1500}` + "\n\n" +
+				`{"name":"Dune","stock":2}` + "\n",
+			want: []record{
+				{Row: 1, Book: models.Books{Isbn_13: "9780306406157", Name: "The Hobbit", Price: 1500}},
+				{Row: 2, Book: models.Books{Name: "Dune", Stock: 2}},
+			},
+		},
+		{
+			name:   "jsonl row errors",
+			format: JSONLines,
+			input:  `{"name":"Dune"` + "\n" + `{"name":"Emma","price":"cheap"}` + "\n" + `{"name":"Emma"}`,
+			want: []record{
+				{Row: 3, Book: models.Books{Name: "Emma"}},
+			},
+			wantRowErr: []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := decodeAll(tt.input, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []record
+			var gotRowErr []int
+			for _, rec := range records {
+				if rec.Err != nil {
+					gotRowErr = append(gotRowErr, rec.Row)
+					continue
+				}
+				got = append(got, rec)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decoded %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotRowErr, tt.wantRowErr) {
+				t.Errorf("rows %v failed, want %v", gotRowErr, tt.wantRowErr)
+			}
+		})
+	}
+}
+
+func TestCredits(t *testing.T) {
+	tests := []struct {
+		name    string
+		credits []models.BookAuthor
+		want    string
+	}{
+		{"none", nil, ""},
+		{"author", []models.BookAuthor{{Name: "Jane Austen", Role: models.AuthorRoleAuthor}}, "Jane Austen"},
+		{
+			"roles and commas",
+			[]models.BookAuthor{{Name: "Tolkien, J. R. R.", Role: models.AuthorRoleAuthor}, {Name: "Alan Lee (artist)", Role: models.AuthorRoleIllustrator}},
+			"Tolkien, J. R. R.; Alan Lee (artist) (illustrator)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatCredits(tt.credits)
+			if got != tt.want {
+				t.Errorf("formatCredits = %q, want %q", got, tt.want)
+			}
+			parsed, err := parseCredits(got)
+			if err != nil || !reflect.DeepEqual(parsed, tt.credits) {
+				t.Errorf("parseCredits(%q) = %+v, %v, want %+v", got, parsed, err, tt.credits)
+			}
+		})
+	}
+	if _, err := parseCredits("Jane Austen; ; Emma"); err == nil {
+		t.Error("parseCredits accepted an empty author")
+	}
+}
+
+func TestDecodeInvalidInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+	}{
+		{"unknown csv column", CSV, "name,colour\nDune,red\n"},
+		{"jsonl line too long", JSONLines, `{"name":"` + strings.Repeat("a", maxLineSize) + `"}`},
+		{"onix cannot be imported", ONIX, "<ONIXMessage/>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeAll(tt.input, tt.format); !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("decode error = %v, want %v", err, ErrInvalidInput)
+			}
+		})
+	}
+}
+
+func TestDecodeStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := decode(strings.NewReader("name\nDune\nEmma\n"), CSV, func(record) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("decode = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
@@ -0,0 +1,351 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/isbn"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultBatchSize is how many rows an Importer writes at once unless told
+// otherwise.
+const DefaultBatchSize = 500
+
+const (
+	Created = "created"
+	Updated = "updated"
+	Failed  = "failed"
+)
+
+var validate = validator.New()
+
+// RowResult says what became of one imported row.
+type RowResult struct {
+	Row     int    `json:"row"`
+	Isbn_13 string `json:"isbn_13,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report tallies an import and lists the result of every row.
+type Report struct {
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Failed  int         `json:"failed"`
+	Rows    []RowResult `json:"rows"`
+}
+
+func (r *Report) add(result RowResult) {
+	switch result.Status {
+	case Created:
+		r.Created++
+	case Updated:
+		r.Updated++
+	case Failed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// Importer upserts books into a collection by ISBN-13.
+type Importer struct {
+	Books     *mongo.Collection
+	BatchSize int
+	// Author and Publisher find or create the author and the publisher a
+	// row names, so imported books are linked to them like books added
+	// through the API. Without them the names are stored as given, for the
+	// migrations to link later.
+	Author    func(ctx context.Context, name, bio string) (models.Author, error)
+	Publisher func(ctx context.Context, name string) (models.Publisher, error)
+}
+
+type pendingRow struct {
+	row  int
+	book models.Books
+}
+
+// links remembers the authors and publishers resolved during one import.
+type links struct {
+	authors    map[string]models.Author
+	publishers map[string]models.Publisher
+}
+
+// Import reads books from r and upserts them by ISBN, in batches. Rows that
+// fail validation or cannot be written are reported and skipped. Stock is
+// only set for new books; the stock of existing books is changed through
+// stock adjustments.
+//
+// Each book is credited to the authors its row lists, or else to the author
+// named by author_name, and linked to the publisher named by its
+// publication, unless it is already linked that way, in which case its
+// links, co-authors included, are kept. The category is stored as text
+// only: placing books in the category tree is done through the admin API.
+//
+// Batches already written stay written when Import fails part way, and
+// the report returned with the error covers them.
+func (im *Importer) Import(ctx context.Context, r io.Reader, format Format) (*Report, error) {
+	batchSize := im.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	report := &Report{Rows: []RowResult{}}
+	resolved := &links{authors: map[string]models.Author{}, publishers: map[string]models.Publisher{}}
+	var pending []pendingRow
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := im.write(ctx, pending, resolved, report)
+		pending = pending[:0]
+		return err
+	}
+
+	err := decode(r, format, func(rec record) error {
+		book, err := prepare(rec)
+		if err != nil {
+			report.add(RowResult{Row: rec.Row, Isbn_13: book.Isbn_13, Status: Failed, Error: err.Error()})
+			return nil
+		}
+		pending = append(pending, pendingRow{row: rec.Row, book: book})
+		if len(pending) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	// Rows that failed validation were reported ahead of their batch.
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		return report.Rows[i].Row < report.Rows[j].Row
+	})
+	return report, err
+}
+
+// prepare validates a decoded row and returns its book with both ISBNs
+// filled in.
+func prepare(rec record) (models.Books, error) {
+	if rec.Err != nil {
+		return models.Books{}, rec.Err
+	}
+	book := rec.Book
+	if err := validate.Struct(book); err != nil {
+		return book, err
+	}
+	isbn10, isbn13, err := isbn.Reconcile(book.Isbn_10, book.Isbn_13)
+	if err != nil {
+		return book, err
+	}
+	if isbn13 == "" {
+		return models.Books{}, errors.New("isbn_13 or isbn_10 is required")
+	}
+	book.Isbn_10, book.Isbn_13 = isbn10, isbn13
+	return book, nil
+}
+
+// upsert returns the write that saves book, with linked holding its author
+// and publisher fields.
+func upsert(book models.Books, linked bson.M) mongo.WriteModel {
+	now := time.Now()
+	set := bson.M{
+		"name":         book.Name,
+		"isbn_10":      book.Isbn_10,
+		"isbn_13":      book.Isbn_13,
+		"published_at": book.Published_at,
+		"genre":        book.Genre,
+		"category":     book.Category,
+		"description":  book.Description,
+		"price":        book.Price,
+		"updated_at":   now,
+	}
+	for field, value := range linked {
+		set[field] = value
+	}
+	update := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"stock":      book.Stock,
+			"reserved":   0,
+			"sold_count": 0,
+			"created_at": now,
+		},
+	}
+	return mongo.NewUpdateOneModel().
+		SetFilter(bson.M{"isbn_13": book.Isbn_13}).
+		SetUpdate(update).
+		SetUpsert(true)
+}
+
+// link returns the author and publisher fields to write for each pending
+// row. Rows are credited to the authors they list, or else to the author
+// named by author_name. Books already linked as their row gives keep their
+// links, so a re-import does not relink them.
+func (im *Importer) link(ctx context.Context, pending []pendingRow, resolved *links) ([]bson.M, error) {
+	isbns := make([]string, len(pending))
+	for i, p := range pending {
+		isbns[i] = p.book.Isbn_13
+	}
+	cursor, err := im.Books.Find(ctx,
+		bson.M{"isbn_13": bson.M{"$in": isbns}},
+		options.Find().SetProjection(bson.M{"isbn_13": 1, "authors": 1, "author_name": 1, "publisher_id": 1, "publication": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var found []models.Books
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	existing := make(map[string]models.Books, len(found))
+	for _, book := range found {
+		existing[book.Isbn_13] = book
+	}
+
+	linked := make([]bson.M, len(pending))
+	for i, p := range pending {
+		current, ok := existing[p.book.Isbn_13]
+		fields := bson.M{}
+
+		authorName := strings.TrimSpace(p.book.Author_name)
+		switch {
+		case ok && len(current.Authors) > 0 && len(p.book.Authors) == 0 && current.Author_name == authorName:
+		case ok && len(current.Authors) > 0 && sameCredits(current.Authors, p.book.Authors):
+		case im.Author == nil || (authorName == "" && len(p.book.Authors) == 0):
+			fields["author_name"], fields["author_info"] = p.book.Author_name, p.book.Author_info
+		default:
+			if err := im.credit(ctx, p.book, resolved, fields); err != nil {
+				return nil, err
+			}
+		}
+
+		publisherName := strings.TrimSpace(p.book.Publication)
+		switch {
+		case ok && current.Publisher_id != nil && current.Publication == publisherName:
+		case im.Publisher == nil || publisherName == "":
+			fields["publication"] = p.book.Publication
+		default:
+			publisher, seen := resolved.publishers[publisherName]
+			if !seen {
+				if publisher, err = im.Publisher(ctx, publisherName); err != nil {
+					return nil, err
+				}
+				resolved.publishers[publisherName] = publisher
+			}
+			fields["publisher_id"], fields["publication"] = publisher.ID, publisher.Name
+		}
+		linked[i] = fields
+	}
+	return linked, nil
+}
+
+// credit links book to the authors its row lists, or else to the author
+// named by author_name, finding or creating them, and sets the author
+// fields to write in fields.
+func (im *Importer) credit(ctx context.Context, book models.Books, resolved *links, fields bson.M) error {
+	credits := book.Authors
+	if len(credits) == 0 {
+		credits = []models.BookAuthor{{Name: strings.TrimSpace(book.Author_name), Role: models.AuthorRoleAuthor}}
+	}
+	linked := make([]models.BookAuthor, len(credits))
+	bios := map[primitive.ObjectID]string{}
+	for i, credit := range credits {
+		name := strings.TrimSpace(credit.Name)
+		author, seen := resolved.authors[name]
+		if !seen {
+			// The row's author_info is the bio of its first author.
+			bio := ""
+			if i == 0 {
+				bio = book.Author_info
+			}
+			var err error
+			if author, err = im.Author(ctx, name, bio); err != nil {
+				return err
+			}
+			resolved.authors[name] = author
+		}
+		role := credit.Role
+		if role == "" {
+			role = models.AuthorRoleAuthor
+		}
+		linked[i] = models.BookAuthor{Author_id: author.ID, Name: author.Name, Role: role}
+		bios[author.ID] = author.Bio
+	}
+
+	named := models.Byline(linked)
+	names := make([]string, len(named))
+	for i, credit := range named {
+		names[i] = credit.Name
+	}
+	fields["authors"] = linked
+	fields["author_name"], fields["author_info"] = strings.Join(names, ", "), bios[named[0].Author_id]
+	return nil
+}
+
+// sameCredits reports whether a book credited with current is already
+// linked as a row listing credits asks, going by names and roles.
+func sameCredits(current, credits []models.BookAuthor) bool {
+	if len(current) != len(credits) {
+		return false
+	}
+	for i, credit := range credits {
+		role := credit.Role
+		if role == "" {
+			role = models.AuthorRoleAuthor
+		}
+		if current[i].Name != strings.TrimSpace(credit.Name) || current[i].Role != role {
+			return false
+		}
+	}
+	return true
+}
+
+func (im *Importer) write(ctx context.Context, pending []pendingRow, resolved *links, report *Report) error {
+	linked, err := im.link(ctx, pending, resolved)
+	if err != nil {
+		return fmt.Errorf("linking rows %d to %d: %w", pending[0].row, pending[len(pending)-1].row, err)
+	}
+	writes := make([]mongo.WriteModel, len(pending))
+	for i, p := range pending {
+		writes[i] = upsert(p.book, linked[i])
+	}
+
+	result, err := im.Books.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	failed := map[int]string{}
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			failed[writeErr.Index] = writeErr.Message
+			if mongo.IsDuplicateKeyError(writeErr) {
+				failed[writeErr.Index] = "another book already has this ISBN"
+			}
+		}
+	} else if err != nil {
+		return fmt.Errorf("writing rows %d to %d: %w", pending[0].row, pending[len(pending)-1].row, err)
+	}
+
+	for i, p := range pending {
+		row := RowResult{Row: p.row, Isbn_13: p.book.Isbn_13}
+		switch {
+		case failed[i] != "":
+			row.Status, row.Error = Failed, failed[i]
+		case result.UpsertedIDs[int64(i)] != nil:
+			row.Status = Created
+		default:
+			row.Status = Updated
+		}
+		report.add(row)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package catalog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestLinkCreditsEveryListedAuthor(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	pratchett := models.Author{ID: primitive.NewObjectID(), Name: "Terry Pratchett", Bio: "Discworld"}
+	gaiman := models.Author{ID: primitive.NewObjectID(), Name: "Neil Gaiman"}
+	byName := map[string]models.Author{pratchett.Name: pratchett, gaiman.Name: gaiman}
+
+	tests := []struct {
+		name     string
+		book     models.Books
+		want     []models.BookAuthor
+		wantName string
+	}{
+		{
+			name: "authors column",
+			book: models.Books{Author_name: "Terry Pratchett, Neil Gaiman", Authors: []models.BookAuthor{
+				{Name: "Terry Pratchett", Role: models.AuthorRoleAuthor},
+				{Name: "Neil Gaiman", Role: models.AuthorRoleEditor},
+			}},
+			want: []models.BookAuthor{
+				{Author_id: pratchett.ID, Name: "Terry Pratchett", Role: models.AuthorRoleAuthor},
+				{Author_id: gaiman.ID, Name: "Neil Gaiman", Role: models.AuthorRoleEditor},
+			},
+			wantName: "Terry Pratchett",
+		},
+		{
+			name:     "only author_name",
+			book:     models.Books{Author_name: " Neil Gaiman "},
+			want:     []models.BookAuthor{{Author_id: gaiman.ID, Name: "Neil Gaiman", Role: models.AuthorRoleAuthor}},
+			wantName: "Neil Gaiman",
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			var asked []string
+			im := &Importer{Books: mt.Coll, Author: func(ctx context.Context, name, bio string) (models.Author, error) {
+				asked = append(asked, name)
+				return byName[name], nil
+			}}
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.books", mtest.FirstBatch))
+			tt.book.Isbn_13 = "9780306406157"
+			links := &links{authors: map[string]models.Author{}, publishers: map[string]models.Publisher{}}
+			linked, err := im.link(ctx, []pendingRow{{row: 1, book: tt.book}}, links)
+			if err != nil {
+				mt.Fatal(err)
+			}
+			if got := linked[0]["authors"]; !reflect.DeepEqual(got, tt.want) {
+				mt.Errorf("authors = %+v, want %+v", got, tt.want)
+			}
+			if got := linked[0]["author_name"]; got != tt.wantName {
+				mt.Errorf("author_name = %q, want %q", got, tt.wantName)
+			}
+			if len(asked) != len(tt.want) {
+				mt.Errorf("looked up authors %q, want one lookup per credit", asked)
+			}
+		})
+	}
+
+	mt.Run("already linked", func(mt *mtest.T) {
+		im := &Importer{Books: mt.Coll, Author: func(ctx context.Context, name, bio string) (models.Author, error) {
+			mt.Fatalf("looked up %q for a book that is already linked", name)
+			return models.Author{}, nil
+		}}
+		current := bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "isbn_13", Value: "9780306406157"},
+			{Key: "author_name", Value: "Terry Pratchett"},
+			{Key: "authors", Value: bson.A{
+				bson.D{{Key: "author_id", Value: pratchett.ID}, {Key: "name", Value: "Terry Pratchett"}, {Key: "role", Value: "author"}},
+				bson.D{{Key: "author_id", Value: gaiman.ID}, {Key: "name", Value: "Neil Gaiman"}, {Key: "role", Value: "editor"}},
+			}},
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.books", mtest.FirstBatch, current))
+		book := models.Books{Isbn_13: "9780306406157", Author_name: "Terry Pratchett", Authors: []models.BookAuthor{
+			{Name: "Terry Pratchett"},
+			{Name: "Neil Gaiman", Role: models.AuthorRoleEditor},
+		}}
+		links := &links{authors: map[string]models.Author{}, publishers: map[string]models.Publisher{}}
+		linked, err := im.link(ctx, []pendingRow{{row: 1, book: book}}, links)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if _, ok := linked[0]["authors"]; ok {
+			mt.Errorf("relinked authors: %+v", linked[0])
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+)
+
+// ONIX writes an ONIX for Books 3.0 message, the format the book trade
+// exchanges product data in. It can be exported but not imported.
+const ONIX Format = "onix"
+
+const onixNamespace = "http://ns.editeur.org/onix/3.0/reference"
+
+// Codes from the ONIX code lists used in products.
+const (
+	onixNotificationConfirmed = "03" // List 1: confirmed on publication
+	onixIDProprietary         = "01" // List 5
+	onixIDISBN10              = "02"
+	onixIDISBN13              = "15"
+	onixSingleItem            = "00"  // List 2: single-component retail product
+	onixBook                  = "BA"  // List 150: book, detail unspecified
+	onixDistinctiveTitle      = "01"  // List 15
+	onixProductLevel          = "01"  // List 149
+	onixByAuthor              = "A01" // List 17
+	onixIllustratedBy         = "A12"
+	onixEditedBy              = "B01"
+	onixTranslatedBy          = "B06"
+	onixKeywords              = "20" // List 26
+	onixDescription           = "03" // List 153
+	onixAnyAudience           = "00" // List 154
+	onixPublisher             = "01" // List 45
+	onixPublicationDate       = "01" // List 163
+	onixPublisherSupplier     = "01" // List 93
+	onixInStock               = "21" // List 65
+	onixOutOfStock            = "31"
+	onixRetailPrice           = "02" // List 58: RRP including tax
+)
+
+var onixContributorRoles = map[string]string{
+	models.AuthorRoleAuthor:      onixByAuthor,
+	models.AuthorRoleEditor:      onixEditedBy,
+	models.AuthorRoleTranslator:  onixTranslatedBy,
+	models.AuthorRoleIllustrator: onixIllustratedBy,
+}
+
+type onixWriter struct {
+	w        io.Writer
+	encoder  *xml.Encoder
+	sender   string
+	currency string
+}
+
+func (ow *onixWriter) begin() error {
+	_, err := fmt.Fprintf(ow.w, "%s<ONIXMessage release=\"3.0\" xmlns=\"%s\">\n", xml.Header, onixNamespace)
+	if err != nil {
+		return err
+	}
+	ow.encoder.Indent("", "  ")
+	return ow.encoder.Encode(onixHeader{
+		Sender:       onixSender{Name: ow.sender},
+		SentDateTime: time.Now().UTC().Format("20060102T1504Z"),
+	})
+}
+
+func (ow *onixWriter) write(book models.Books) error {
+	return ow.encoder.Encode(ow.product(book))
+}
+
+func (ow *onixWriter) end() error {
+	_, err := io.WriteString(ow.w, "\n</ONIXMessage>\n")
+	return err
+}
+
+func (ow *onixWriter) product(book models.Books) onixProduct {
+	product := onixProduct{
+		RecordReference:  book.ID.Hex(),
+		NotificationType: onixNotificationConfirmed,
+		Identifiers: []onixIdentifier{
+			{Type: onixIDProprietary, TypeName: "Book ID", Value: book.ID.Hex()},
+		},
+		Descriptive: onixDescriptiveDetail{
+			Composition: onixSingleItem,
+			Form:        onixBook,
+			Title: onixTitleDetail{
+				Type: onixDistinctiveTitle,
+				Element: onixTitleElement{
+					Level: onixProductLevel,
+					Text:  book.Name,
+				},
+			},
+		},
+		Publishing: onixPublishingDetail{
+			Publisher: onixPublisherDetail{Role: onixPublisher, Name: book.Publication},
+		},
+		Supply: onixProductSupply{
+			Detail: onixSupplyDetail{
+				Supplier:     onixSupplier{Role: onixPublisherSupplier, Name: ow.sender},
+				Availability: onixInStock,
+				Price: onixPrice{
+					Type:     onixRetailPrice,
+					Amount:   strconv.Itoa(book.Price),
+					Currency: ow.currency,
+				},
+			},
+		},
+	}
+	if book.Isbn_13 != "" {
+		product.Identifiers = append(product.Identifiers, onixIdentifier{Type: onixIDISBN13, Value: book.Isbn_13})
+	}
+	if book.Isbn_10 != "" {
+		product.Identifiers = append(product.Identifiers, onixIdentifier{Type: onixIDISBN10, Value: book.Isbn_10})
+	}
+	for i, credit := range book.Authors {
+		product.Descriptive.Contributors = append(product.Descriptive.Contributors, onixContributor{
+			Sequence: i + 1,
+			Role:     onixContributorRoles[credit.Role],
+			Name:     credit.Name,
+		})
+	}
+	if len(product.Descriptive.Contributors) == 0 && book.Author_name != "" {
+		product.Descriptive.Contributors = []onixContributor{{
+			Sequence: 1,
+			Role:     onixByAuthor,
+			Name:     book.Author_name,
+		}}
+	}
+	// Author_info is the bio of the first credited author.
+	if len(product.Descriptive.Contributors) > 0 {
+		product.Descriptive.Contributors[0].Biography = book.Author_info
+	}
+	for _, keyword := range []string{book.Genre, book.Category} {
+		if keyword != "" {
+			product.Descriptive.Subjects = append(product.Descriptive.Subjects, onixSubject{Scheme: onixKeywords, Heading: keyword})
+		}
+	}
+	if book.Description != "" {
+		product.Collateral = &onixCollateralDetail{
+			Text: onixTextContent{Type: onixDescription, Audience: onixAnyAudience, Text: book.Description},
+		}
+	}
+	if book.Published_at != nil {
+		product.Publishing.Date = &onixPublishingDate{
+			Role: onixPublicationDate,
+			Date: book.Published_at.UTC().Format("20060102"),
+		}
+	}
+	if book.Out_of_stock {
+		product.Supply.Detail.Availability = onixOutOfStock
+	}
+	return product
+}
+
+type onixHeader struct {
+	XMLName      xml.Name   `xml:"Header"`
+	Sender       onixSender `xml:"Sender"`
+	SentDateTime string     `xml:"SentDateTime"`
+}
+
+type onixSender struct {
+	Name string `xml:"SenderName"`
+}
+
+type onixProduct struct {
+	XMLName          xml.Name              `xml:"Product"`
+	RecordReference  string                `xml:"RecordReference"`
+	NotificationType string                `xml:"NotificationType"`
+	Identifiers      []onixIdentifier      `xml:"ProductIdentifier"`
+	Descriptive      onixDescriptiveDetail `xml:"DescriptiveDetail"`
+	Collateral       *onixCollateralDetail `xml:"CollateralDetail,omitempty"`
+	Publishing       onixPublishingDetail  `xml:"PublishingDetail"`
+	Supply           onixProductSupply     `xml:"ProductSupply"`
+}
+
+type onixIdentifier struct {
+	Type     string `xml:"ProductIDType"`
+	TypeName string `xml:"IDTypeName,omitempty"`
+	Value    string `xml:"IDValue"`
+}
+
+type onixDescriptiveDetail struct {
+	Composition  string            `xml:"ProductComposition"`
+	Form         string            `xml:"ProductForm"`
+	Title        onixTitleDetail   `xml:"TitleDetail"`
+	Contributors []onixContributor `xml:"Contributor"`
+	Subjects     []onixSubject     `xml:"Subject"`
+}
+
+type onixTitleDetail struct {
+	Type    string           `xml:"TitleType"`
+	Element onixTitleElement `xml:"TitleElement"`
+}
+
+type onixTitleElement struct {
+	Level string `xml:"TitleElementLevel"`
+	Text  string `xml:"TitleText"`
+}
+
+type onixContributor struct {
+	Sequence  int    `xml:"SequenceNumber"`
+	Role      string `xml:"ContributorRole"`
+	Name      string `xml:"PersonName"`
+	Biography string `xml:"BiographicalNote,omitempty"`
+}
+
+type onixSubject struct {
+	Scheme  string `xml:"SubjectSchemeIdentifier"`
+	Heading string `xml:"SubjectHeadingText"`
+}
+
+type onixCollateralDetail struct {
+	Text onixTextContent `xml:"TextContent"`
+}
+
+type onixTextContent struct {
+	Type     string `xml:"TextType"`
+	Audience string `xml:"ContentAudience"`
+	Text     string `xml:"Text"`
+}
+
+type onixPublishingDetail struct {
+	Publisher onixPublisherDetail `xml:"Publisher"`
+	Date      *onixPublishingDate `xml:"PublishingDate,omitempty"`
+}
+
+type onixPublisherDetail struct {
+	Role string `xml:"PublishingRole"`
+	Name string `xml:"PublisherName"`
+}
+
+type onixPublishingDate struct {
+	Role string `xml:"PublishingDateRole"`
+	Date string `xml:"Date"`
+}
+
+type onixProductSupply struct {
+	Detail onixSupplyDetail `xml:"SupplyDetail"`
+}
+
+type onixSupplyDetail struct {
+	Supplier     onixSupplier `xml:"Supplier"`
+	Availability string       `xml:"ProductAvailability"`
+	Price        onixPrice    `xml:"Price"`
+}
+
+type onixSupplier struct {
+	Role string `xml:"SupplierRole"`
+	Name string `xml:"SupplierName"`
+}
+
+type onixPrice struct {
+	Type     string `xml:"PriceType"`
+	Amount   string `xml:"PriceAmount"`
+	Currency string `xml:"CurrencyCode"`
+}
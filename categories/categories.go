@@ -0,0 +1,345 @@
+// Package categories manages the category tree books are filed under.
+//
+// Every category stores its ancestors, so moving or renaming a category
+// rewrites the categories below it. Books store the IDs of the categories
+// they are filed under and, for display, the breadcrumb of each, which are
+// rewritten whenever a category on the path changes.
+package categories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// writeBatchSize is how many documents are rewritten at a time.
+const writeBatchSize = 500
+
+var (
+	ErrNotFound    = errors.New("category not found")
+	ErrDuplicate   = errors.New("a category with this name already exists under the same parent")
+	ErrCycle       = errors.New("a category cannot be moved below itself")
+	ErrHasChildren = errors.New("category has subcategories")
+	ErrInUse       = errors.New("category still has books")
+)
+
+// UnknownCategoryError names a category ID that does not exist.
+type UnknownCategoryError struct {
+	Category_id primitive.ObjectID
+}
+
+func (e *UnknownCategoryError) Error() string {
+	return "category " + e.Category_id.Hex() + " not found"
+}
+
+var categoriesCollection *mongo.Collection = database.OpenCollection(database.Client, "categories")
+var booksCollection *mongo.Collection = database.OpenCollection(database.Client, "books")
+
+// Find returns the category with id.
+func Find(ctx context.Context, id primitive.ObjectID) (models.Category, error) {
+	var category models.Category
+	err := categoriesCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return category, ErrNotFound
+	}
+	return category, err
+}
+
+// All returns every category, sorted by name.
+func All(ctx context.Context) ([]models.Category, error) {
+	cursor, err := categoriesCollection.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	categories := []models.Category{}
+	err = cursor.All(ctx, &categories)
+	return categories, err
+}
+
+// Children returns the categories directly below the one with id.
+func Children(ctx context.Context, id primitive.ObjectID) ([]models.Category, error) {
+	cursor, err := categoriesCollection.Find(ctx, bson.M{"parent_id": id},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	children := []models.Category{}
+	err = cursor.All(ctx, &children)
+	return children, err
+}
+
+// Subtree returns the ID of the category with id and of every category
+// below it.
+func Subtree(ctx context.Context, id primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := categoriesCollection.Find(ctx,
+		bson.M{"ancestors.id": id},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var descendants []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &descendants); err != nil {
+		return nil, err
+	}
+	ids := []primitive.ObjectID{id}
+	for _, descendant := range descendants {
+		ids = append(ids, descendant.ID)
+	}
+	return ids, nil
+}
+
+// Create adds a category called name below the category parentID, or at
+// the root when parentID is nil.
+func Create(ctx context.Context, name string, parentID *primitive.ObjectID) (models.Category, error) {
+	category := models.Category{
+		ID:         primitive.NewObjectID(),
+		Name:       name,
+		Parent_id:  parentID,
+		Ancestors:  []models.CategoryRef{},
+		Created_at: time.Now(),
+	}
+	category.Updated_at = category.Created_at
+	if parentID != nil {
+		parent, err := Find(ctx, *parentID)
+		if err != nil {
+			return category, err
+		}
+		category.Ancestors = parent.Breadcrumb()
+	}
+	_, err := categoriesCollection.InsertOne(ctx, category)
+	if mongo.IsDuplicateKeyError(err) {
+		return category, ErrDuplicate
+	}
+	return category, err
+}
+
+// Rename renames the category with id, in the ancestors of the categories
+// below it and in the breadcrumbs of books too.
+func Rename(ctx context.Context, id primitive.ObjectID, name string) (models.Category, error) {
+	var category models.Category
+	err := categoriesCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"name": name, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return category, ErrNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return category, ErrDuplicate
+	}
+	if err != nil {
+		return category, err
+	}
+
+	_, err = categoriesCollection.UpdateMany(ctx,
+		bson.M{"ancestors.id": id},
+		bson.M{"$set": bson.M{"ancestors.$[renamed].name": name}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"renamed.id": id}},
+		}),
+	)
+	if err != nil {
+		return category, err
+	}
+	return category, refreshBooks(ctx, id)
+}
+
+// Move puts the category with id, and everything below it, under the
+// category parentID, or at the root when parentID is nil.
+func Move(ctx context.Context, id primitive.ObjectID, parentID *primitive.ObjectID) (models.Category, error) {
+	category, err := Find(ctx, id)
+	if err != nil {
+		return category, err
+	}
+	ancestors := []models.CategoryRef{}
+	if parentID != nil {
+		if *parentID == id {
+			return category, ErrCycle
+		}
+		parent, err := Find(ctx, *parentID)
+		if err != nil {
+			return category, err
+		}
+		for _, ancestor := range parent.Ancestors {
+			if ancestor.ID == id {
+				return category, ErrCycle
+			}
+		}
+		ancestors = parent.Breadcrumb()
+	}
+
+	category.Parent_id, category.Ancestors, category.Updated_at = parentID, ancestors, time.Now()
+	_, err = categoriesCollection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"parent_id": parentID, "ancestors": ancestors, "updated_at": category.Updated_at}})
+	if mongo.IsDuplicateKeyError(err) {
+		return category, ErrDuplicate
+	}
+	if err != nil {
+		return category, err
+	}
+
+	// Below the moved category, paths keep their tail from the moved
+	// category down and take its new path as their head.
+	cursor, err := categoriesCollection.Find(ctx, bson.M{"ancestors.id": id})
+	if err != nil {
+		return category, err
+	}
+	var descendants []models.Category
+	if err := cursor.All(ctx, &descendants); err != nil {
+		return category, err
+	}
+	head := category.Breadcrumb()
+	var writes []mongo.WriteModel
+	for _, descendant := range descendants {
+		path := append([]models.CategoryRef(nil), head...)
+		for i, ancestor := range descendant.Ancestors {
+			if ancestor.ID == id {
+				path = append(path, descendant.Ancestors[i+1:]...)
+				break
+			}
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": descendant.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"ancestors": path}}))
+	}
+	if err := bulkWrite(ctx, categoriesCollection, writes); err != nil {
+		return category, err
+	}
+	return category, refreshBooks(ctx, id)
+}
+
+// Delete deletes the category with id, which must have no subcategories
+// and no books.
+func Delete(ctx context.Context, id primitive.ObjectID) error {
+	if count, err := categoriesCollection.CountDocuments(ctx, bson.M{"parent_id": id}, options.Count().SetLimit(1)); err != nil {
+		return err
+	} else if count > 0 {
+		return ErrHasChildren
+	}
+	if count, err := booksCollection.CountDocuments(ctx, bson.M{"category_ids": id}, options.Count().SetLimit(1)); err != nil {
+		return err
+	} else if count > 0 {
+		return ErrInUse
+	}
+	result, err := categoriesCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Breadcrumbs checks that every category in ids exists and returns the
+// breadcrumb of each, in the same order.
+func Breadcrumbs(ctx context.Context, ids []primitive.ObjectID) ([][]models.CategoryRef, error) {
+	found, err := load(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	breadcrumbs := make([][]models.CategoryRef, len(ids))
+	for i, id := range ids {
+		category, ok := found[id]
+		if !ok {
+			return nil, &UnknownCategoryError{Category_id: id}
+		}
+		breadcrumbs[i] = category.Breadcrumb()
+	}
+	return breadcrumbs, nil
+}
+
+func load(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]models.Category, error) {
+	cursor, err := categoriesCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var categories []models.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+	return byID, nil
+}
+
+// refreshBooks rewrites the breadcrumbs of the books filed anywhere in the
+// subtree of the category with id.
+func refreshBooks(ctx context.Context, id primitive.ObjectID) error {
+	subtree, err := Subtree(ctx, id)
+	if err != nil {
+		return err
+	}
+	cursor, err := booksCollection.Find(ctx,
+		bson.M{"category_ids": bson.M{"$in": subtree}},
+		options.Find().SetProjection(bson.M{"category_ids": 1}))
+	if err != nil {
+		return err
+	}
+	var books []models.Books
+	if err := cursor.All(ctx, &books); err != nil {
+		return err
+	}
+
+	var ids []primitive.ObjectID
+	for _, book := range books {
+		ids = append(ids, book.Category_ids...)
+	}
+	found, err := load(ctx, ids)
+	if err != nil {
+		return err
+	}
+	var writes []mongo.WriteModel
+	for _, book := range books {
+		breadcrumbs := make([][]models.CategoryRef, 0, len(book.Category_ids))
+		for _, categoryID := range book.Category_ids {
+			if category, ok := found[categoryID]; ok {
+				breadcrumbs = append(breadcrumbs, category.Breadcrumb())
+			}
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": book.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"breadcrumbs": breadcrumbs}}))
+	}
+	return bulkWrite(ctx, booksCollection, writes)
+}
+
+func bulkWrite(ctx context.Context, collection *mongo.Collection, writes []mongo.WriteModel) error {
+	for start := 0; start < len(writes); start += writeBatchSize {
+		end := min(start+writeBatchSize, len(writes))
+		if _, err := collection.BulkWrite(ctx, writes[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureIndexes creates the indexes for walking the tree, the one keeping
+// sibling names unique, and the one finding the books in a category.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := categoriesCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ancestors.id", Value: 1}}},
+		{Keys: bson.D{{Key: "parent_id", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = booksCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "category_ids", Value: 1}},
+	})
+	return err
+}
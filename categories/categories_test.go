@@ -0,0 +1,278 @@
+package categories
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// tree is Fiction > Fantasy > Epic, with Nonfiction beside Fiction.
+type tree struct {
+	fiction, fantasy, epic, nonfiction models.Category
+}
+
+func newTree() tree {
+	fiction := models.Category{ID: primitive.NewObjectID(), Name: "Fiction", Ancestors: []models.CategoryRef{}}
+	fantasy := models.Category{ID: primitive.NewObjectID(), Name: "Fantasy", Parent_id: &fiction.ID, Ancestors: fiction.Breadcrumb()}
+	epic := models.Category{ID: primitive.NewObjectID(), Name: "Epic", Parent_id: &fantasy.ID, Ancestors: fantasy.Breadcrumb()}
+	nonfiction := models.Category{ID: primitive.NewObjectID(), Name: "Nonfiction", Ancestors: []models.CategoryRef{}}
+	return tree{fiction, fantasy, epic, nonfiction}
+}
+
+// doc converts v to a document for a mock response.
+func doc(t testing.TB, v any) bson.D {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d bson.D
+	if err := bson.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// found answers a find with docs.
+func found(t testing.TB, docs ...any) bson.D {
+	batch := make([]bson.D, len(docs))
+	for i, v := range docs {
+		batch[i] = doc(t, v)
+	}
+	return mtest.CreateCursorResponse(0, "test.categories", mtest.FirstBatch, batch...)
+}
+
+var updated = mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+
+// setUpdate is an update statement that $sets fields of type T.
+type setUpdate[T any] struct {
+	Q struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	U struct {
+		Set T `bson:"$set"`
+	}
+}
+
+// sentUpdates returns the update statements mt sent, in order.
+func sentUpdates[T any](mt *mtest.T) []setUpdate[T] {
+	var all []setUpdate[T]
+	for _, event := range mt.GetAllStartedEvents() {
+		if event.CommandName != "update" {
+			continue
+		}
+		var command struct{ Updates []setUpdate[T] }
+		if err := bson.Unmarshal(event.Command, &command); err != nil {
+			mt.Fatal(err)
+		}
+		all = append(all, command.Updates...)
+	}
+	return all
+}
+
+func TestSubtree(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	tr := newTree()
+
+	mt.Run("with descendants", func(mt *mtest.T) {
+		categoriesCollection = mt.Coll
+		mt.AddMockResponses(found(mt, tr.fantasy, tr.epic))
+		got, err := Subtree(ctx, tr.fiction.ID)
+		want := []primitive.ObjectID{tr.fiction.ID, tr.fantasy.ID, tr.epic.ID}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			mt.Fatalf("Subtree = %v, %v, want %v", got, err, want)
+		}
+		var command struct {
+			Filter bson.M
+		}
+		if err := bson.Unmarshal(mt.GetStartedEvent().Command, &command); err != nil {
+			mt.Fatal(err)
+		}
+		if command.Filter["ancestors.id"] != tr.fiction.ID {
+			mt.Errorf("filter = %v, want the categories below %s", command.Filter, tr.fiction.ID.Hex())
+		}
+	})
+	mt.Run("leaf", func(mt *mtest.T) {
+		categoriesCollection = mt.Coll
+		mt.AddMockResponses(found(mt))
+		got, err := Subtree(ctx, tr.epic.ID)
+		if err != nil || !reflect.DeepEqual(got, []primitive.ObjectID{tr.epic.ID}) {
+			mt.Fatalf("Subtree = %v, %v, want only the category itself", got, err)
+		}
+	})
+}
+
+func TestMove(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	tr := newTree()
+	duplicate := mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"})
+
+	type ancestors struct {
+		Ancestors []models.CategoryRef
+	}
+	tests := []struct {
+		name      string
+		id        primitive.ObjectID
+		parentID  *primitive.ObjectID
+		responses []bson.D
+		wantErr   error
+		// wantAncestors are the ancestors written, the moved category's
+		// first and then its descendants', whether or not they stuck.
+		wantAncestors [][]models.CategoryRef
+	}{
+		{
+			name:     "subtree to another parent",
+			id:       tr.fantasy.ID,
+			parentID: &tr.nonfiction.ID,
+			responses: []bson.D{
+				found(mt, tr.fantasy), found(mt, tr.nonfiction), updated,
+				found(mt, tr.epic), updated,
+				// The books below, of which there are none.
+				found(mt, tr.epic), found(mt), found(mt),
+			},
+			wantAncestors: [][]models.CategoryRef{
+				tr.nonfiction.Breadcrumb(),
+				{{ID: tr.nonfiction.ID, Name: "Nonfiction"}, {ID: tr.fantasy.ID, Name: "Fantasy"}},
+			},
+		},
+		{
+			name: "to the root",
+			id:   tr.epic.ID,
+			responses: []bson.D{
+				found(mt, tr.epic), updated, found(mt),
+				found(mt), found(mt), found(mt),
+			},
+			wantAncestors: [][]models.CategoryRef{{}},
+		},
+		{
+			name:      "below itself",
+			id:        tr.fiction.ID,
+			parentID:  &tr.epic.ID,
+			responses: []bson.D{found(mt, tr.fiction), found(mt, tr.epic)},
+			wantErr:   ErrCycle,
+		},
+		{
+			name:      "onto itself",
+			id:        tr.fantasy.ID,
+			parentID:  &tr.fantasy.ID,
+			responses: []bson.D{found(mt, tr.fantasy)},
+			wantErr:   ErrCycle,
+		},
+		{
+			name:      "unknown parent",
+			id:        tr.fantasy.ID,
+			parentID:  &primitive.ObjectID{1},
+			responses: []bson.D{found(mt, tr.fantasy), found(mt)},
+			wantErr:   ErrNotFound,
+		},
+		{
+			name:          "next to a sibling of the same name",
+			id:            tr.fantasy.ID,
+			parentID:      &tr.nonfiction.ID,
+			responses:     []bson.D{found(mt, tr.fantasy), found(mt, tr.nonfiction), duplicate},
+			wantErr:       ErrDuplicate,
+			wantAncestors: [][]models.CategoryRef{tr.nonfiction.Breadcrumb()},
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			categoriesCollection, booksCollection = mt.Coll, mt.Coll
+			mt.AddMockResponses(tt.responses...)
+			category, err := Move(ctx, tt.id, tt.parentID)
+			if !errors.Is(err, tt.wantErr) {
+				mt.Fatalf("Move error = %v, want %v", err, tt.wantErr)
+			}
+			var got [][]models.CategoryRef
+			for _, update := range sentUpdates[ancestors](mt) {
+				got = append(got, update.U.Set.Ancestors)
+			}
+			if !reflect.DeepEqual(got, tt.wantAncestors) {
+				mt.Errorf("wrote ancestors %+v, want %+v", got, tt.wantAncestors)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(category.Ancestors, tt.wantAncestors[0]) {
+				mt.Errorf("Move = %+v, want ancestors %+v", category, tt.wantAncestors[0])
+			}
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	tr := newTree()
+	renamed := tr.fantasy
+	renamed.Name = "High Fantasy"
+	epic := tr.epic
+	epic.Ancestors = renamed.Breadcrumb()
+	book := models.Books{ID: primitive.NewObjectID(), Category_ids: []primitive.ObjectID{tr.epic.ID, tr.nonfiction.ID}}
+
+	mt.Run("renamed everywhere", func(mt *mtest.T) {
+		categoriesCollection, booksCollection = mt.Coll, mt.Coll
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: doc(mt, renamed)}),
+			updated,
+			found(mt, epic),
+			found(mt, book),
+			found(mt, epic, tr.nonfiction),
+			updated,
+		)
+		category, err := Rename(ctx, tr.fantasy.ID, "High Fantasy")
+		if err != nil || category.Name != "High Fantasy" {
+			mt.Fatalf("Rename = %+v, %v", category, err)
+		}
+
+		events := mt.GetAllStartedEvents()
+		var descendants struct {
+			Updates []struct {
+				Q            bson.M
+				U            bson.M
+				ArrayFilters []bson.M `bson:"arrayFilters"`
+				Multi        bool
+			}
+		}
+		if err := bson.Unmarshal(events[1].Command, &descendants); err != nil {
+			mt.Fatal(err)
+		}
+		statement := descendants.Updates[0]
+		set, _ := statement.U["$set"].(bson.M)
+		if statement.Q["ancestors.id"] != tr.fantasy.ID || !statement.Multi ||
+			set["ancestors.$[renamed].name"] != "High Fantasy" ||
+			len(statement.ArrayFilters) != 1 || statement.ArrayFilters[0]["renamed.id"] != tr.fantasy.ID {
+			mt.Errorf("descendants updated with %+v", statement)
+		}
+
+		type breadcrumbs struct {
+			Breadcrumbs [][]models.CategoryRef
+		}
+		// The first update is the one to the descendants checked above.
+		books := sentUpdates[breadcrumbs](mt)[1:]
+		want := [][]models.CategoryRef{epic.Breadcrumb(), tr.nonfiction.Breadcrumb()}
+		if len(books) != 1 || books[0].Q.ID != book.ID || !reflect.DeepEqual(books[0].U.Set.Breadcrumbs, want) {
+			mt.Errorf("books updated with %+v, want breadcrumbs %+v", books, want)
+		}
+	})
+	mt.Run("unknown category", func(mt *mtest.T) {
+		categoriesCollection, booksCollection = mt.Coll, mt.Coll
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+		if _, err := Rename(ctx, tr.fantasy.ID, "High Fantasy"); !errors.Is(err, ErrNotFound) {
+			mt.Fatalf("Rename error = %v, want %v", err, ErrNotFound)
+		}
+	})
+	mt.Run("name taken by a sibling", func(mt *mtest.T) {
+		categoriesCollection, booksCollection = mt.Coll, mt.Coll
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 11000, Name: "DuplicateKey", Message: "E11000 duplicate key error"}))
+		if _, err := Rename(ctx, tr.fantasy.ID, "Epic"); !errors.Is(err, ErrDuplicate) {
+			mt.Fatalf("Rename error = %v, want %v", err, ErrDuplicate)
+		}
+		if n := len(mt.GetAllStartedEvents()); n != 1 {
+			mt.Errorf("sent %d commands after the rename failed, want none", n-1)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+// Command catalog imports books into the store's catalogue and exports them
+// in bulk.
+//
+// Usage:
+//
+//	catalog import [-format csv|jsonl] [-batch n] file
+//	catalog export [-format csv|jsonl|onix] [-filter json] [-o file]
+//	catalog migrate-authors
+//	catalog migrate-publishers
+//
+// The import format defaults to the file's extension. A file of "-" reads
+// standard input. The report is written to standard output as JSON.
+// Imported books are credited to the author in their author_name and linked
+// to the publisher in their publication, both created as needed. Their
+// category is only stored as text; books are placed in the category tree
+// through PATCH /admin/book/:book_id with category_ids. Servers
+// using the embedded search index pick up imported books when they restart;
+// use POST /admin/books/import to have them indexed straight away.
+//
+// Exports go to standard output unless -o is given. -filter takes a Mongo
+// query on the books collection in extended JSON, such as
+// '{"genre": "Fantasy"}'.
+//
+// migrate-authors credits books that only have a free-text author_name
+// with an author from the authors collection, creating authors as needed.
+// It only touches books without authors, so it can be run again at any
+// time. migrate-publishers does the same for publication and the
+// publishers collection.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SHUBHAM91285/online_book_store/authors"
+	"github.com/SHUBHAM91285/online_book_store/catalog"
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/publishers"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("catalog: ")
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "import":
+		importBooks(os.Args[2:])
+	case "export":
+		exportBooks(os.Args[2:])
+	case "migrate-authors":
+		migrateAuthors()
+	case "migrate-publishers":
+		migratePublishers()
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog import [-format csv|jsonl] [-batch n] file")
+	fmt.Fprintln(os.Stderr, "       catalog export [-format csv|jsonl|onix] [-filter json] [-o file]")
+	fmt.Fprintln(os.Stderr, "       catalog migrate-authors")
+	fmt.Fprintln(os.Stderr, "       catalog migrate-publishers")
+	os.Exit(2)
+}
+
+func importBooks(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	formatName := flags.String("format", "", "csv or jsonl (default from the file extension)")
+	batchSize := flags.Int("batch", catalog.DefaultBatchSize, "rows written per batch")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	path := flags.Arg(0)
+
+	if *formatName == "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	format, err := catalog.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	importer := &catalog.Importer{
+		Books:     database.OpenCollection(database.Client, "books"),
+		BatchSize: *batchSize,
+		Author:    authors.Resolve,
+		Publisher: publishers.Resolve,
+	}
+	report, importErr := importer.Import(context.Background(), input, format)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	if importErr != nil {
+		log.Fatal(importErr)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func exportBooks(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	formatName := flags.String("format", "csv", "csv, jsonl or onix")
+	filterJSON := flags.String("filter", "{}", "Mongo query selecting the books, in extended JSON")
+	outputPath := flags.String("o", "", "file to write to (default standard output)")
+	sender := flags.String("sender", "Online Book Store", "sender name in ONIX headers")
+	currency := flags.String("currency", "USD", "currency of ONIX prices")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		usage()
+	}
+
+	format, err := catalog.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var filter bson.M
+	if err := bson.UnmarshalExtJSON([]byte(*filterJSON), false, &filter); err != nil {
+		log.Fatal("invalid -filter: ", err)
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputPath != "" {
+		file, err := os.Create(*outputPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	exporter := &catalog.Exporter{
+		Books:    database.OpenCollection(database.Client, "books"),
+		Sender:   *sender,
+		Currency: *currency,
+	}
+	count, err := exporter.Export(context.Background(), output, format, filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("exported", count, "books")
+}
+
+func migrateAuthors() {
+	report, err := authors.Migrate(context.Background())
+	log.Println("created", report.Authors_created, "authors and linked", report.Books_linked, "books")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func migratePublishers() {
+	report, err := publishers.Migrate(context.Background())
+	log.Println("created", report.Publishers_created, "publishers and linked", report.Books_linked, "books")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/authors"
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var authorsCollection *mongo.Collection = database.OpenCollection(database.Client, "authors")
+
+// GetAuthor returns an author and a page of the books crediting them,
+// paged like GetBooks.
+func GetAuthor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("author_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+			return
+		}
+		p, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var author models.Author
+		err = authorsCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&author)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading author"})
+			return
+		}
+		books, err := listBooks(ctx, p, bson.M{"authors.author_id": objID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing books"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"author": author, "books": books})
+	}
+}
+
+func AddAuthor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var author models.Author
+		if err := c.BindJSON(&author); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(author); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		author.ID = primitive.NewObjectID()
+		author.Created_at = time.Now()
+		author.Updated_at = author.Created_at
+		_, err := authorsCollection.InsertOne(ctx, author)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "an author with this name already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "author is not created"})
+			return
+		}
+		c.JSON(http.StatusCreated, author)
+	}
+}
+
+// UpdateAuthor changes an author's name or bio, and the copies of them on
+// the books crediting the author.
+func UpdateAuthor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("author_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+			return
+		}
+		var request struct {
+			Name *string `json:"name"`
+			Bio  *string `json:"bio"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		update := bson.M{"updated_at": time.Now()}
+		if request.Name != nil {
+			if *request.Name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+				return
+			}
+			update["name"] = *request.Name
+		}
+		if request.Bio != nil {
+			update["bio"] = *request.Bio
+		}
+
+		var author models.Author
+		err = authorsCollection.FindOneAndUpdate(ctx,
+			bson.M{"_id": objID},
+			bson.M{"$set": update},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&author)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+			return
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "an author with this name already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "author update failed"})
+			return
+		}
+
+		if err := authors.Refresh(ctx, objID); err != nil {
+			log.Println("failed to update the books of author", objID.Hex()+":", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "author updated, but not all of their books were"})
+			return
+		}
+		if err := indexBooks(ctx, bson.M{"authors.author_id": objID}); err != nil {
+			log.Println("failed to index the books of author", objID.Hex()+":", err)
+		}
+		c.JSON(http.StatusOK, author)
+	}
+}
+
+// DeleteAuthor deletes an author no book credits.
+func DeleteAuthor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("author_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid author ID"})
+			return
+		}
+		credited, err := authors.IsCredited(ctx, objID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete author"})
+			return
+		}
+		if credited {
+			c.JSON(http.StatusConflict, gin.H{"error": "author is still credited on books"})
+			return
+		}
+		result, err := authorsCollection.DeleteOne(ctx, bson.M{"_id": objID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete author"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "author not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "author deleted successfully"})
+	}
+}
+
+// creditAuthors resolves the authors credited on book and fills in its
+// author caches. It answers the request and returns false if the credits
+// are invalid.
+func creditAuthors(ctx context.Context, c *gin.Context, book *models.Books) bool {
+	credits, name, info, err := authors.Credit(ctx, book.Authors)
+	var unknown *authors.UnknownAuthorError
+	switch {
+	case errors.Is(err, authors.ErrNoAuthors), errors.Is(err, authors.ErrInvalidRole), errors.As(err, &unknown):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading authors"})
+		return false
+	}
+	book.Authors, book.Author_name, book.Author_info = credits, name, info
+	return true
+}
@@ -3,11 +3,16 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/SHUBHAM91285/online_book_store/database"
-	"github.com/SHUBHAM91285/online_book_store/tokens"
+	"github.com/SHUBHAM91285/online_book_store/inventory"
+	"github.com/SHUBHAM91285/online_book_store/isbn"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
 
 	"github.com/SHUBHAM91285/online_book_store/models"
 	"github.com/gin-gonic/gin"
@@ -20,27 +25,46 @@ import (
 var booksCollection *mongo.Collection = database.OpenCollection(database.Client, "books")
 var validate = validator.New()
 
+// GetBooks lists the catalogue a page at a time. See parsePage for the
+// accepted query parameters.
 func GetBooks() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		result, err := booksCollection.Find(context.TODO(), bson.M{})
 		defer cancel()
+
+		p, err := parsePage(c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing books"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		var booksNames []string
-		for result.Next(ctx) {
-			var book models.Books
-			if err := result.Decode(&book); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode book"})
-				return
-			}
-			booksNames = append(booksNames, book.Name)
+		body, err := listBooks(ctx, p, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing books"})
+			return
 		}
-		c.JSON(http.StatusOK, booksNames)
+		c.JSON(http.StatusOK, body)
 	}
 }
+
+// listBooks returns page p of the books matching filter, along with the
+// number of matching books.
+func listBooks(ctx context.Context, p page, filter bson.M) (gin.H, error) {
+	total, err := booksCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := booksCollection.Find(ctx, p.filter(filter), p.findOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	books := []models.Books{}
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return p.response(books, total)
+}
+
 func GetBookByParameter() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -68,52 +92,88 @@ func GetBookByParameter() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding books"})
 			return
 		}
-		c.JSON(http.StatusOK, books)
+		for i := range books {
+			books[i] = books[i].WithAvailability()
+		}
+		if len(books) == 0 {
+			c.JSON(http.StatusOK, gin.H{"books": []models.Books{}, "did_you_mean": didYouMean(Parameter)})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"books": books})
 	}
 }
 
-func AddBook() gin.HandlerFunc {
+// GetBookByISBN returns the book with the ISBN-10 or ISBN-13 in the path.
+func GetBookByISBN() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		var foundUser models.User
-		var book models.Books
+		defer cancel()
 
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		_, isbn13, err := isbn.Parse(c.Param("isbn"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		tokenString = tokenString[len("Bearer "):]
-
-		claims, msg := tokens.VerifyToken(tokenString)
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		var book models.Books
+		err = booksCollection.FindOne(ctx, bson.M{"isbn_13": isbn13}).Decode(&book)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
 			return
 		}
-		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
-		defer cancel()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error finding book"})
 			return
 		}
+		c.JSON(http.StatusOK, book.WithAvailability())
+	}
+}
 
-		if foundUser.Role != "admin" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "only admin is allowed to add books"})
+func AddBook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+		var book models.Books
+
+		if err := c.BindJSON(&book); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if err := c.BindJSON(&book); err != nil {
+		if len(book.Authors) > 0 && !creditAuthors(ctx, c, &book) {
+			return
+		}
+		if book.Publisher_id != nil && !linkPublisher(ctx, c, &book) {
+			return
+		}
+		book.Breadcrumbs = nil
+		if len(book.Category_ids) > 0 && !fileBook(ctx, c, &book) {
+			return
+		}
+		if err := validate.Struct(book); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var err error
+		book.Isbn_10, book.Isbn_13, err = isbn.Reconcile(book.Isbn_10, book.Isbn_13)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 		book.ID = primitive.NewObjectID()
+		book.Reserved = 0
+		book.Sold_count = 0
+		book.Cover = nil
 		book.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		book.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		_, insertErr := booksCollection.InsertOne(ctx, book)
+		if mongo.IsDuplicateKeyError(insertErr) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a book with this ISBN already exists"})
+			return
+		}
 		if insertErr != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Book is not created"})
 			return
 		}
-		defer cancel()
+		indexBook(ctx, book)
 		c.JSON(http.StatusOK, gin.H{"message": "book inserted properly"})
 	}
 }
@@ -122,31 +182,8 @@ func UpdateBookInfo() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var foundUser models.User
 		var book models.Books
 
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-			return
-		}
-		tokenString = tokenString[len("Bearer "):]
-
-		claims, msg := tokens.VerifyToken(tokenString)
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			return
-		}
-		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
-			return
-		}
-		if foundUser.Role != "admin" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "only admin is allowed to add books"})
-			return
-		}
-
 		bookID := c.Param("book_id")
 		objID, err := primitive.ObjectIDFromHex(bookID)
 		if err != nil {
@@ -161,11 +198,31 @@ func UpdateBookInfo() gin.HandlerFunc {
 
 		updateObj := bson.D{}
 
-		if book.Author_info != "" {
-			updateObj = append(updateObj, bson.E{"author_info", book.Author_info})
+		if book.Isbn_10 != "" || book.Isbn_13 != "" {
+			isbn10, isbn13, err := isbn.Reconcile(book.Isbn_10, book.Isbn_13)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"isbn_10", isbn10}, bson.E{"isbn_13", isbn13})
 		}
-		if book.Author_name != "" {
-			updateObj = append(updateObj, bson.E{"author_name", book.Author_name})
+
+		if book.Authors != nil {
+			if !creditAuthors(ctx, c, &book) {
+				return
+			}
+			updateObj = append(updateObj,
+				bson.E{"authors", book.Authors},
+				bson.E{"author_name", book.Author_name},
+				bson.E{"author_info", book.Author_info},
+			)
+		} else {
+			if book.Author_info != "" {
+				updateObj = append(updateObj, bson.E{"author_info", book.Author_info})
+			}
+			if book.Author_name != "" {
+				updateObj = append(updateObj, bson.E{"author_name", book.Author_name})
+			}
 		}
 		if book.Category != "" {
 			updateObj = append(updateObj, bson.E{"category", book.Category})
@@ -179,9 +236,29 @@ func UpdateBookInfo() gin.HandlerFunc {
 		if book.Name != "" {
 			updateObj = append(updateObj, bson.E{"name", book.Name})
 		}
-		if book.Publication != "" {
+		if book.Category_ids != nil {
+			if !fileBook(ctx, c, &book) {
+				return
+			}
+			updateObj = append(updateObj,
+				bson.E{"category_ids", book.Category_ids},
+				bson.E{"breadcrumbs", book.Breadcrumbs},
+			)
+		}
+		if book.Publisher_id != nil {
+			if !linkPublisher(ctx, c, &book) {
+				return
+			}
+			updateObj = append(updateObj,
+				bson.E{"publisher_id", book.Publisher_id},
+				bson.E{"publication", book.Publication},
+			)
+		} else if book.Publication != "" {
 			updateObj = append(updateObj, bson.E{"publication", book.Publication})
 		}
+		if book.Published_at != nil {
+			updateObj = append(updateObj, bson.E{"published_at", book.Published_at})
+		}
 		book.Updated_at = time.Now()
 
 		updateObj = append(updateObj, bson.E{"updated_at", book.Updated_at})
@@ -194,12 +271,17 @@ func UpdateBookInfo() gin.HandlerFunc {
 			bson.D{{"$set", updateObj}},
 		)
 
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a book with this ISBN already exists"})
+			return
+		}
 		if err != nil {
 			fmt.Println(err)
 			msg := fmt.Sprintf("book update failed")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		reindexBook(ctx, objID)
 		c.JSON(http.StatusOK, "data updated successfully")
 
 	}
@@ -210,46 +292,93 @@ func DeleteBook() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var foundUser models.User
-
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		bookID := c.Param("book_id")
+		objID, err := primitive.ObjectIDFromHex(bookID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
 			return
 		}
-		tokenString = tokenString[len("Bearer "):]
-
-		claims, msg := tokens.VerifyToken(tokenString)
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		var deleted models.Books
+		err = booksCollection.FindOneAndDelete(ctx, bson.M{"_id": objID}).Decode(&deleted)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
 			return
 		}
-		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete book"})
 			return
 		}
-		if foundUser.Role != "admin" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "only admin is allowed to add books"})
+		deleteCover(ctx, objID, deleted.Cover)
+
+		if err := bookSearch.Delete(ctx, objID); err != nil {
+			log.Println("failed to remove book", objID.Hex(), "from the search index:", err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "book deleted successfully"})
+	}
+}
+
+func AdjustStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Delta  int    `json:"delta" validate:"required"`
+			Reason string `json:"reason" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		bookID := c.Param("book_id")
-		objID, err := primitive.ObjectIDFromHex(bookID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		result, err := booksCollection.DeleteOne(ctx, bson.M{"_id": objID})
+		objID, err := primitive.ObjectIDFromHex(c.Param("book_id"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete book"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
 			return
 		}
 
-		if result.DeletedCount == 0 {
+		stock, err := inventory.Adjust(ctx, objID, request.Delta, request.Reason, middleware.CurrentUser(c).Email)
+		switch {
+		case err == inventory.ErrBookNotFound:
 			c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
 			return
+		case err == inventory.ErrNegativeStock:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to adjust stock"})
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"message": "stock adjusted successfully", "stock": stock, "out_of_stock": stock <= 0})
+	}
+}
 
-		c.JSON(http.StatusOK, gin.H{"message": "book deleted successfully"})
+// MigrateStock gives books stored before stock was tracked the stock level
+// in LEGACY_BOOK_STOCK, 0 by default. Books left without stock cannot be
+// bought until it is set through the stock endpoint, so they are logged.
+func MigrateStock(ctx context.Context) error {
+	initial := 0
+	if value := os.Getenv("LEGACY_BOOK_STOCK"); value != "" {
+		var err error
+		initial, err = strconv.Atoi(value)
+		if err != nil || initial < 0 {
+			return fmt.Errorf("invalid LEGACY_BOOK_STOCK %q", value)
+		}
+	}
+	books, err := inventory.MigrateStock(ctx, initial)
+	if err != nil {
+		return err
+	}
+	if len(books) > 0 {
+		log.Printf("gave %d books without a stock level a stock of %d", len(books), initial)
+	}
+	if initial == 0 {
+		for _, book := range books {
+			log.Printf("book %s (%s) is out of stock until its stock is set with PATCH /admin/book/%s/stock", book.ID.Hex(), book.Name, book.ID.Hex())
+		}
 	}
+	return nil
 }
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/authors"
+	"github.com/SHUBHAM91285/online_book_store/catalog"
+	"github.com/SHUBHAM91285/online_book_store/publishers"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// catalogTimeout bounds bulk catalogue operations, which take much longer
+// than a single request.
+const catalogTimeout = 10 * time.Minute
+
+var catalogImporter = &catalog.Importer{
+	Books:     booksCollection,
+	Author:    authors.Resolve,
+	Publisher: publishers.Resolve,
+}
+var catalogExporter = &catalog.Exporter{
+	Books:    booksCollection,
+	Sender:   catalogSenderFromEnv(),
+	Currency: paymentCurrency,
+}
+
+// catalogSenderFromEnv names the store in ONIX exports, from
+// CATALOG_SENDER_NAME.
+func catalogSenderFromEnv() string {
+	if sender := os.Getenv("CATALOG_SENDER_NAME"); sender != "" {
+		return sender
+	}
+	return "Online Book Store"
+}
+
+// catalogContentTypes maps the content types accepted for imports to their
+// formats.
+var catalogContentTypes = map[string]catalog.Format{
+	"text/csv":                catalog.CSV,
+	"application/x-ndjson":    catalog.JSONLines,
+	"application/jsonl":       catalog.JSONLines,
+	"application/x-jsonlines": catalog.JSONLines,
+}
+
+// ImportBooks upserts the books in the request body by ISBN and reports
+// what became of each row. The body is CSV or JSON Lines, as given by
+// ?format= or else the content type.
+func ImportBooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), catalogTimeout)
+		defer cancel()
+
+		format, err := requestCatalogFormat(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		report, err := catalogImporter.Import(ctx, c.Request.Body, format)
+		syncImportedBooks(ctx, report)
+		if errors.Is(err, catalog.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "report": report})
+			return
+		}
+		if err != nil {
+			log.Println("catalog import failed:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "import failed part way", "report": report})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ExportBooks streams the books matching the search filters in the query as
+// CSV, JSON Lines or ONIX 3.0, as given by ?format=.
+func ExportBooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), catalogTimeout)
+		defer cancel()
+
+		format, err := catalog.ParseFormat(c.DefaultQuery("format", "csv"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter, err := searchFilter(ctx, c)
+		if err != nil {
+			respondSearchFilterError(c, err)
+			return
+		}
+
+		c.Header("Content-Type", catalog.ContentTypes[format])
+		c.Header("Content-Disposition", `attachment; filename="books.`+catalog.Extensions[format]+`"`)
+		c.Status(http.StatusOK)
+		// The status is sent with the first bytes, so a failure part way can
+		// only cut the export short.
+		if _, err := catalogExporter.Export(ctx, c.Writer, format, filter); err != nil {
+			log.Println("catalog export failed:", err)
+		}
+	}
+}
+
+func requestCatalogFormat(c *gin.Context) (catalog.Format, error) {
+	if value := c.Query("format"); value != "" {
+		return catalog.ParseFormat(value)
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	if format, ok := catalogContentTypes[mediaType]; ok {
+		return format, nil
+	}
+	return "", errors.New("give the format as ?format=csv or ?format=jsonl, or send text/csv or application/x-ndjson")
+}
+
+// syncImportedBooks brings the search index up to date with the rows that
+// were written.
+func syncImportedBooks(ctx context.Context, report *catalog.Report) {
+	var isbns []string
+	for _, row := range report.Rows {
+		if row.Status != catalog.Failed {
+			isbns = append(isbns, row.Isbn_13)
+		}
+	}
+	if len(isbns) == 0 {
+		return
+	}
+	if err := indexBooks(ctx, bson.M{"isbn_13": bson.M{"$in": isbns}}); err != nil {
+		log.Println("failed to index imported books:", err)
+	}
+}
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/categories"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// categoryNode is a category with the categories below it, as returned by
+// GetCategoryTree.
+type categoryNode struct {
+	models.Category
+	Children []*categoryNode `json:"children"`
+}
+
+// GetCategoryTree returns the whole category tree, siblings sorted by name.
+func GetCategoryTree() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		all, err := categories.All(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing categories"})
+			return
+		}
+		nodes := make(map[primitive.ObjectID]*categoryNode, len(all))
+		for _, category := range all {
+			nodes[category.ID] = &categoryNode{Category: category, Children: []*categoryNode{}}
+		}
+		roots := []*categoryNode{}
+		for _, category := range all {
+			node := nodes[category.ID]
+			if parent, ok := nodes[derefID(category.Parent_id)]; ok {
+				parent.Children = append(parent.Children, node)
+			} else {
+				roots = append(roots, node)
+			}
+		}
+		c.JSON(http.StatusOK, roots)
+	}
+}
+
+// GetCategory returns a category with its breadcrumb and the categories
+// directly below it.
+func GetCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("category_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		category, err := categories.Find(ctx, objID)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		children, err := categories.Children(ctx, objID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing categories"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"category": category, "breadcrumb": category.Breadcrumb(), "children": children})
+	}
+}
+
+// GetCategoryBooks lists the books filed under a category or anywhere
+// below it, paged like GetBooks.
+func GetCategoryBooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("category_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		p, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := categories.Find(ctx, objID); err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		subtree, err := categories.Subtree(ctx, objID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing categories"})
+			return
+		}
+		body, err := listBooks(ctx, p, bson.M{"category_ids": bson.M{"$in": subtree}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing books"})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+// AddCategory creates a category below parent_id, or at the root.
+func AddCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Name      string              `json:"name" validate:"required"`
+			Parent_id *primitive.ObjectID `json:"parent_id"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		category, err := categories.Create(ctx, request.Name, request.Parent_id)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, category)
+	}
+}
+
+// RenameCategory renames a category, updating the breadcrumbs that show it.
+func RenameCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("category_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		var request struct {
+			Name string `json:"name" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		category, err := categories.Rename(ctx, objID, request.Name)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, category)
+	}
+}
+
+// MoveCategory moves a category and everything below it under parent_id,
+// or to the root when parent_id is null.
+func MoveCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("category_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		var request struct {
+			Parent_id *primitive.ObjectID `json:"parent_id"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		category, err := categories.Move(ctx, objID, request.Parent_id)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, category)
+	}
+}
+
+// DeleteCategory deletes a category without subcategories or books.
+func DeleteCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("category_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		if err := categories.Delete(ctx, objID); err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "category deleted successfully"})
+	}
+}
+
+func respondCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, categories.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, categories.ErrDuplicate), errors.Is(err, categories.ErrHasChildren), errors.Is(err, categories.ErrInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, categories.ErrCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("category operation failed:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "category operation failed"})
+	}
+}
+
+// fileBook checks the categories book is filed under and fills in its
+// breadcrumbs. It answers the request and returns false if a category does
+// not exist.
+func fileBook(ctx context.Context, c *gin.Context, book *models.Books) bool {
+	breadcrumbs, err := categories.Breadcrumbs(ctx, book.Category_ids)
+	var unknown *categories.UnknownCategoryError
+	if errors.As(err, &unknown) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading categories"})
+		return false
+	}
+	book.Breadcrumbs = breadcrumbs
+	return true
+}
+
+func derefID(id *primitive.ObjectID) primitive.ObjectID {
+	if id == nil {
+		return primitive.NilObjectID
+	}
+	return *id
+}
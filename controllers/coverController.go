@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/covers"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/storage"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// multipartOverhead is allowed on top of covers.MaxSize for the rest of an
+// upload form.
+const multipartOverhead = 1 << 20
+
+var mediaStore storage.BlobStore = mediaStoreFromEnv()
+
+// mediaStoreFromEnv keeps uploads in MEDIA_DIR, served by this server from
+// MEDIA_BASE_URL, unless MEDIA_STORE is "s3", in which case they go to the
+// S3_BUCKET on S3_ENDPOINT.
+func mediaStoreFromEnv() storage.BlobStore {
+	if os.Getenv("MEDIA_STORE") == "s3" {
+		store, err := storage.NewS3Store(storage.S3Config{
+			Endpoint:   os.Getenv("S3_ENDPOINT"),
+			Access_key: os.Getenv("S3_ACCESS_KEY"),
+			Secret_key: os.Getenv("S3_SECRET_KEY"),
+			Bucket:     os.Getenv("S3_BUCKET"),
+			Use_ssl:    os.Getenv("S3_USE_SSL") != "false",
+			Public_url: os.Getenv("S3_PUBLIC_URL"),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return store
+	}
+
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "media"
+	}
+	baseURL := os.Getenv("MEDIA_BASE_URL")
+	if baseURL == "" {
+		baseURL = appBaseURL + "/media"
+	}
+	return storage.NewLocalStore(dir, baseURL)
+}
+
+// LocalMediaDir returns the directory uploads are kept in when this server
+// serves them itself, or "" when they are stored elsewhere.
+func LocalMediaDir() string {
+	if store, ok := mediaStore.(*storage.LocalStore); ok {
+		return store.Dir
+	}
+	return ""
+}
+
+// UploadCover sets the cover of a book from the image in the "cover" field
+// of a multipart form, replacing any earlier cover.
+func UploadCover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("book_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, covers.MaxSize+multipartOverhead)
+		header, err := c.FormFile("cover")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": covers.ErrTooLarge.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a cover image is required in the cover form field"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read cover"})
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, covers.MaxSize+1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read cover"})
+			return
+		}
+
+		count, err := booksCollection.CountDocuments(ctx, bson.M{"_id": objID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading book"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+			return
+		}
+
+		cover, err := covers.Save(ctx, mediaStore, objID, data)
+		switch {
+		case errors.Is(err, covers.ErrTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, covers.ErrUnsupportedType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, covers.ErrInvalidImage):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Println("failed to store cover of book", objID.Hex()+":", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store cover"})
+			return
+		}
+
+		previous, err := setCover(ctx, objID, cover)
+		if err != nil {
+			covers.Delete(ctx, mediaStore, cover)
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save cover"})
+			return
+		}
+		deleteCover(ctx, objID, previous)
+		c.JSON(http.StatusOK, gin.H{"cover": cover})
+	}
+}
+
+// RemoveCover deletes the cover of a book.
+func RemoveCover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("book_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+			return
+		}
+		previous, err := setCover(ctx, objID, nil)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove cover"})
+			return
+		}
+		deleteCover(ctx, objID, previous)
+		c.JSON(http.StatusOK, gin.H{"message": "cover removed"})
+	}
+}
+
+// setCover replaces the cover of a book and returns the one it had.
+func setCover(ctx context.Context, bookID primitive.ObjectID, cover *models.Cover) (*models.Cover, error) {
+	var book models.Books
+	err := booksCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": bookID},
+		bson.M{"$set": bson.M{"cover": cover, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&book)
+	return book.Cover, err
+}
+
+// deleteCover removes the blobs of a cover no longer in use. The book no
+// longer refers to them, so a failure only leaves garbage behind.
+func deleteCover(ctx context.Context, bookID primitive.ObjectID, cover *models.Cover) {
+	if err := covers.Delete(ctx, mediaStore, cover); err != nil {
+		log.Println("failed to delete old cover of book", bookID.Hex()+":", err)
+	}
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxFacetValues bounds how many values are counted for each facet.
+const maxFacetValues = 50
+
+// facetFields maps facet names to the book fields they count. Stages first
+// turn each book into one document per value, for facets over lists; the
+// values of those are told apart by ID, since names need not be unique.
+var facetFields = []struct {
+	Name   string
+	Stages bson.A
+	Field  string
+	ID     string
+}{
+	{"genre", nil, "genre", ""},
+	// A book is counted under each category it is in, which is the last
+	// entry of each of its breadcrumbs.
+	{"category", bson.A{
+		bson.M{"$unwind": "$breadcrumbs"},
+		bson.M{"$addFields": bson.M{"category": bson.M{"$arrayElemAt": bson.A{"$breadcrumbs", -1}}}},
+	}, "category.name", "category.id"},
+	{"publication", nil, "publication", ""},
+	{"author", bson.A{bson.M{"$unwind": "$authors"}}, "authors.name", "authors.author_id"},
+}
+
+// priceBuckets are the lower bounds of the price ranges counted by the
+// price facet. The last range is open ended.
+var priceBuckets = []int{0, 200, 500, 1000, 2000}
+
+type facetValue struct {
+	ID    *primitive.ObjectID `json:"id,omitempty" bson:"id,omitempty"`
+	Value string              `json:"value" bson:"value"`
+	Count int                 `json:"count" bson:"count"`
+}
+
+// priceFacet counts the books priced from Min up to, but excluding, Max.
+type priceFacet struct {
+	Min   int  `json:"min"`
+	Max   *int `json:"max,omitempty"`
+	Count int  `json:"count"`
+}
+
+// bookFacets counts the books matching filter by genre, category,
+// publication, author and price range, most common values first.
+func bookFacets(ctx context.Context, filter bson.M) (map[string]any, error) {
+	facets := bson.M{}
+	for _, f := range facetFields {
+		key, project := "$"+f.Field, bson.M{"_id": 0, "value": 1, "count": 1}
+		if f.ID != "" {
+			key, project["id"] = "$"+f.ID, "$_id"
+		}
+		facets[f.Name] = append(append(bson.A{}, f.Stages...),
+			bson.M{"$match": bson.M{f.Field: bson.M{"$nin": bson.A{nil, ""}}}},
+			bson.M{"$group": bson.M{"_id": key, "value": bson.M{"$first": "$" + f.Field}, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "value", Value: 1}}},
+			bson.M{"$limit": maxFacetValues},
+			bson.M{"$project": project},
+		)
+	}
+	// Everything from the last boundary up lands in the default bucket, so
+	// books with a negative or missing price are left out beforehand
+	// instead of being counted as the most expensive.
+	facets["price"] = bson.A{
+		bson.M{"$match": bson.M{"price": bson.M{"$gte": priceBuckets[0]}}},
+		bson.M{"$bucket": bson.M{
+			"groupBy":    "$price",
+			"boundaries": priceBuckets,
+			"default":    priceBuckets[len(priceBuckets)-1],
+			"output":     bson.M{"count": bson.M{"$sum": 1}},
+		}},
+	}
+
+	cursor, err := booksCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: facets}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Fields map[string][]facetValue `bson:",inline"`
+		Price  []struct {
+			Min   int `bson:"_id"`
+			Count int `bson:"count"`
+		} `bson:"price"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	counts := map[string]any{}
+	for _, f := range facetFields {
+		counts[f.Name] = []facetValue{}
+	}
+	prices := []priceFacet{}
+	if len(results) > 0 {
+		for name, values := range results[0].Fields {
+			counts[name] = values
+		}
+		for _, bucket := range results[0].Price {
+			price := priceFacet{Min: bucket.Min, Count: bucket.Count}
+			for _, bound := range priceBuckets {
+				if bound > bucket.Min {
+					price.Max = &bound
+					break
+				}
+			}
+			prices = append(prices, price)
+		}
+	}
+	counts["price"] = prices
+	return counts, nil
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/SHUBHAM91285/online_book_store/lockout"
+	"github.com/SHUBHAM91285/online_book_store/search"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the controllers' collections rely on.
+func EnsureIndexes(ctx context.Context) error {
+	if store, ok := loginAttemptStore.(*lockout.MongoStore); ok {
+		if err := store.EnsureIndexes(ctx); err != nil {
+			return err
+		}
+	}
+
+	// One index per listing sort, each with _id as the tie breaker the
+	// pagination cursor relies on.
+	var bookIndexes []mongo.IndexModel
+	for _, field := range bookSortFields {
+		bookIndexes = append(bookIndexes, mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}, {Key: "_id", Value: 1}}})
+	}
+	// The exact publication filter and the publication date range of
+	// /books/search; categories index category_ids themselves.
+	for _, field := range []string{"publication", "published_at"} {
+		bookIndexes = append(bookIndexes, mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}})
+	}
+	// Books without an ISBN store it empty, so only non-empty ISBNs need
+	// to be unique.
+	for _, field := range []string{"isbn_10", "isbn_13"} {
+		bookIndexes = append(bookIndexes, mongo.IndexModel{
+			Keys: bson.D{{Key: field, Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{field: bson.M{"$gt": ""}}),
+		})
+	}
+	if _, err := booksCollection.Indexes().CreateMany(ctx, bookIndexes); err != nil {
+		return err
+	}
+
+	switch index := bookSearch.(type) {
+	case *search.MongoIndex:
+		if err := index.EnsureIndexes(ctx); err != nil {
+			return err
+		}
+	case *search.BleveIndex:
+		if err := indexBooks(ctx, bson.M{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
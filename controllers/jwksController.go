@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/SHUBHAM91285/online_book_store/tokens"
+	"github.com/gin-gonic/gin"
+)
+
+func JWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=60")
+		c.JSON(http.StatusOK, tokens.JWKS())
+	}
+}
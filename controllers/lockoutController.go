@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/lockout"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const invalidCredentials = "invalid email or password"
+
+var loginAttemptStore = loginAttemptStoreFromEnv()
+var loginGuard = lockout.NewGuard(loginAttemptStore)
+
+// loginAttemptStoreFromEnv keeps login attempts in Mongo so every instance
+// shares them, unless LOGIN_ATTEMPT_STORE is "memory".
+func loginAttemptStoreFromEnv() lockout.Store {
+	if os.Getenv("LOGIN_ATTEMPT_STORE") == "memory" {
+		return lockout.NewMemoryStore()
+	}
+	return lockout.NewMongoStore(database.OpenCollection(database.Client, "login_attempts"))
+}
+
+// dummyPasswordHash is compared against when the email is unknown, so that
+// a failed login takes as long whether or not the account exists.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	return HashPassword("not-a-real-password")
+})
+
+// checkLoginAllowed answers with 429 and returns false while email or the
+// client's address is throttled.
+func checkLoginAllowed(ctx context.Context, c *gin.Context, email string) bool {
+	wait, err := loginGuard.Check(ctx, email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+	if wait > 0 {
+		c.Header("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, try again later"})
+		return false
+	}
+	return true
+}
+
+// rejectLogin records a failed attempt and answers with the same error for
+// every kind of failure.
+func rejectLogin(ctx context.Context, c *gin.Context, email string) {
+	if err := loginGuard.Failure(ctx, email, c.ClientIP()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": invalidCredentials})
+}
+
+func UnlockAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		var foundUser models.User
+		if err := userCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&foundUser); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		if err := loginGuard.Unlock(ctx, foundUser.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock account"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "account unlocked successfully"})
+	}
+}
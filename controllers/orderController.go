@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/inventory"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/orders"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ordersCollection *mongo.Collection = database.OpenCollection(database.Client, "orders")
+
+const reservationSweepInterval = time.Minute
+
+// reservationTTL is how long checkout holds stock for an unpaid order. It is
+// read from RESERVATION_TTL and defaults to 15 minutes.
+var reservationTTL = reservationTTLFromEnv()
+
+func reservationTTLFromEnv() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("RESERVATION_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 15 * time.Minute
+}
+
+func Checkout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		foundUser := middleware.CurrentUser(c)
+
+		// Take the cart and empty it in one step, so items added while the
+		// order is being created stay in the cart instead of getting lost,
+		// and a second checkout racing this one finds nothing to order.
+		var cartOwner models.User
+		err := userCollection.FindOneAndUpdate(ctx,
+			bson.M{"_id": foundUser.ID, "cart.0": bson.M{"$exists": true}},
+			bson.M{"$set": bson.M{"cart": []models.Cart{}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.Before),
+		).Decode(&cartOwner)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cart is empty"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to checkout"})
+			return
+		}
+		cart := cartOwner.Cart
+
+		order := models.Order{
+			ID:      primitive.NewObjectID(),
+			User_id: foundUser.ID,
+			Status:  models.OrderPlaced,
+		}
+		for _, cartItem := range cart {
+			item, err := snapshotCartItem(ctx, cartItem)
+			if err != nil {
+				restoreCart(foundUser.ID, cart)
+				if err == mongo.ErrNoDocuments {
+					c.JSON(http.StatusConflict, gin.H{"error": "book " + cartItem.Name + " is no longer available"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to checkout"})
+				return
+			}
+			order.Items = append(order.Items, item)
+			order.Total += item.Amount
+		}
+		order.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		order.Updated_at = order.Created_at
+		order.History = []models.StatusChange{{To: models.OrderPlaced, By: foundUser.Email, At: order.Created_at}}
+
+		reservation, err := inventory.Reserve(ctx, order.ID, inventory.LinesFor(order.Items), reservationTTL)
+		if err != nil {
+			restoreCart(foundUser.ID, cart)
+			var insufficient *inventory.InsufficientStockError
+			if errors.As(err, &insufficient) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to checkout"})
+			return
+		}
+		order.Reserved_until = reservation.Expires_at
+
+		if _, err := ordersCollection.InsertOne(ctx, order); err != nil {
+			restoreCart(foundUser.ID, cart)
+			releaseReservation(order.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order is not created"})
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+func GetOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := ordersCollection.Find(ctx,
+			bson.M{"user_id": middleware.CurrentUser(c).ID},
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing orders"})
+			return
+		}
+		defer cursor.Close(ctx)
+		orders := []models.Order{}
+		if err := cursor.All(ctx, &orders); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode orders"})
+			return
+		}
+		c.JSON(http.StatusOK, orders)
+	}
+}
+
+func GetOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+		var order models.Order
+		err = ordersCollection.FindOne(ctx, bson.M{"_id": orderID, "user_id": middleware.CurrentUser(c).ID}).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load order"})
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+func ListOrdersByStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			if !orders.IsStatus(status) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown order status " + status})
+				return
+			}
+			filter["status"] = status
+		}
+		cursor, err := ordersCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing orders"})
+			return
+		}
+		defer cursor.Close(ctx)
+		found := []models.Order{}
+		if err := cursor.All(ctx, &found); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to decode orders"})
+			return
+		}
+		c.JSON(http.StatusOK, found)
+	}
+}
+
+func UpdateOrderStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Status string `json:"status" validate:"required"`
+			Note   string `json:"note"`
+			// Returned says the copies of a shipped or delivered order came
+			// back, so refunding it puts them back into stock. Orders
+			// refunded before shipping are always restocked.
+			Returned bool `json:"returned"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !orders.IsStatus(request.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown order status " + request.Status})
+			return
+		}
+		if request.Status == models.OrderPaid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "orders are marked paid by the payment provider"})
+			return
+		}
+		orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		// The transition is applied before the money is returned, so two
+		// admins refunding the same order at once cannot both reach the
+		// provider.
+		order, err := orders.Transition(ctx, orderID, request.Status, middleware.CurrentUser(c).Email, request.Note)
+		if err != nil {
+			respondTransitionError(c, err)
+			return
+		}
+		if order.Status == models.OrderRefunded {
+			from := order.History[len(order.History)-1].From
+			if from == models.OrderPaid || from == models.OrderPacked || request.Returned {
+				if err := inventory.Restock(ctx, inventory.LinesFor(order.Items)); err != nil {
+					log.Println("failed to restock refunded order", order.ID.Hex()+":", err)
+				}
+			}
+			if err := refundOrder(ctx, order); err != nil {
+				log.Println("failed to refund order", order.ID.Hex()+":", err)
+				recordOrderError(order.ID, "refund_error", err)
+				c.JSON(http.StatusBadGateway, gin.H{"error": "order is refunded but the payment provider refused the refund: " + err.Error()})
+				return
+			}
+		}
+		if order.Status == models.OrderCancelled {
+			releaseReservation(order.ID)
+			voidPayment(order)
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// respondTransitionError writes the response for a failed orders.Transition.
+func respondTransitionError(c *gin.Context, err error) {
+	var illegal *orders.IllegalTransitionError
+	switch {
+	case errors.Is(err, orders.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &illegal), errors.Is(err, orders.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order status"})
+	}
+}
+
+// snapshotCartItem copies the current catalog data of the cart item's book
+// into an order item.
+func snapshotCartItem(ctx context.Context, cartItem models.Cart) (models.OrderItem, error) {
+	filter := bson.M{"_id": cartItem.Book_id}
+	if cartItem.Book_id.IsZero() {
+		// Carts filled before items remembered their book.
+		filter = bson.M{"name": cartItem.Name}
+	}
+	var book models.Books
+	if err := booksCollection.FindOne(ctx, filter).Decode(&book); err != nil {
+		return models.OrderItem{}, err
+	}
+	quantity := max(cartItem.Quantity, 1)
+	return models.OrderItem{
+		Book_id:  book.ID,
+		Name:     book.Name,
+		Author:   book.Author_name,
+		Price:    book.Price,
+		Quantity: quantity,
+		Amount:   book.Price * quantity,
+	}, nil
+}
+
+// releaseReservation gives back the stock held for an order that will not
+// be paid.
+func releaseReservation(orderID primitive.ObjectID) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	err := inventory.Release(ctx, orderID)
+	if err != nil && !errors.Is(err, inventory.ErrReservationNotActive) {
+		log.Println("failed to release reservation of order", orderID.Hex()+":", err)
+	}
+}
+
+// StartReservationSweeper releases the stock of checkouts that were not paid
+// in time and cancels their orders.
+func StartReservationSweeper() {
+	inventory.StartSweeper(reservationSweepInterval, func(ctx context.Context, orderID primitive.ObjectID) {
+		order, err := orders.Transition(ctx, orderID, models.OrderCancelled, "system", "stock reservation expired")
+		var illegal *orders.IllegalTransitionError
+		if err != nil && !errors.As(err, &illegal) {
+			log.Println("failed to cancel expired order", orderID.Hex()+":", err)
+		}
+		if err == nil {
+			voidPayment(order)
+		}
+	})
+}
+
+// restoreCart puts items taken by a failed checkout back into the cart.
+func restoreCart(userID primitive.ObjectID, items []models.Cart) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	userCollection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$push": bson.M{"cart": bson.M{"$each": items}}},
+	)
+}
+
+// cleanupContext is for undoing or finishing up after a request, which has
+// to happen even when the request's own context is what just expired.
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 10*time.Second)
+}
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// bookSortFields maps the values accepted by ?sort= to stored fields.
+var bookSortFields = map[string]string{
+	"price":      "price",
+	"name":       "name",
+	"created_at": "created_at",
+	"popularity": "sold_count",
+}
+
+// bookFields maps the JSON fields ?fields= may select to the stored fields
+// they are read from. Out_of_stock is not stored but derived from the stock
+// levels.
+var bookFields = map[string][]string{
+	"ID": {"_id"}, "name": {"name"}, "isbn_10": {"isbn_10"}, "isbn_13": {"isbn_13"}, "authors": {"authors"}, "author_name": {"author_name"},
+	"price": {"price"}, "description": {"description"}, "author_info": {"author_info"}, "publisher_id": {"publisher_id"},
+	"publication": {"publication"}, "published_at": {"published_at"}, "genre": {"genre"}, "category": {"category"},
+	"category_ids": {"category_ids"}, "breadcrumbs": {"breadcrumbs"}, "stock": {"stock"}, "reserved": {"reserved"},
+	"sold_count": {"sold_count"}, "cover": {"cover"}, "out_of_stock": {"stock", "reserved"},
+	"created_at": {"created_at"}, "updated_at": {"updated_at"},
+}
+
+// page describes which slice of a book listing to return. Listings are
+// addressed either by offset or, for deep pages, by an opaque cursor naming
+// the last book of the previous page.
+type page struct {
+	Limit      int64
+	Offset     int64
+	Sort_field string
+	Descending bool
+	Cursor     *pageCursor
+	Fields     []string
+}
+
+// pageCursor records the sort it was issued for, since its position means
+// nothing in any other order.
+type pageCursor struct {
+	Sort  string             `bson:"s"`
+	Value bson.RawValue      `bson:"v"`
+	ID    primitive.ObjectID `bson:"id"`
+}
+
+// parsePage reads limit, offset, cursor, sort and fields from the query.
+// Sorting defaults to newest first.
+func parsePage(c *gin.Context) (page, error) {
+	p := page{Limit: defaultPageLimit, Sort_field: "created_at", Descending: true}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page{}, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		p.Limit = limit
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || offset < 0 {
+			return page{}, errors.New("offset must be a non-negative number")
+		}
+		p.Offset = offset
+	}
+	if value := c.Query("sort"); value != "" {
+		name := strings.TrimPrefix(value, "-")
+		field, ok := bookSortFields[name]
+		if !ok {
+			return page{}, errors.New("sort must be one of price, name, created_at or popularity, optionally prefixed with -")
+		}
+		p.Sort_field, p.Descending = field, strings.HasPrefix(value, "-")
+	}
+	if value := c.Query("cursor"); value != "" {
+		if p.Offset != 0 {
+			return page{}, errors.New("use either offset or cursor, not both")
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return page{}, errors.New("invalid cursor")
+		}
+		var cursor pageCursor
+		if err := bson.Unmarshal(raw, &cursor); err != nil {
+			return page{}, errors.New("invalid cursor")
+		}
+		if cursor.Sort != p.sort() {
+			return page{}, errors.New("cursor belongs to a listing with a different sort")
+		}
+		p.Cursor = &cursor
+	}
+	if value := c.Query("fields"); value != "" {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if _, ok := bookFields[field]; !ok {
+				return page{}, errors.New("unknown field " + field)
+			}
+			p.Fields = append(p.Fields, field)
+		}
+	}
+	return p, nil
+}
+
+// sort identifies the order of the listing, in the form ?sort= takes.
+func (p page) sort() string {
+	if p.Descending {
+		return "-" + p.Sort_field
+	}
+	return p.Sort_field
+}
+
+// filter narrows base to the books after the cursor, if there is one.
+func (p page) filter(base bson.M) bson.M {
+	if p.Cursor == nil {
+		return base
+	}
+	operator := "$gt"
+	if p.Descending {
+		operator = "$lt"
+	}
+	after := bson.M{"$or": bson.A{
+		bson.M{p.Sort_field: bson.M{operator: p.Cursor.Value}},
+		bson.M{p.Sort_field: p.Cursor.Value, "_id": bson.M{operator: p.Cursor.ID}},
+	}}
+	if len(base) == 0 {
+		return after
+	}
+	return bson.M{"$and": bson.A{base, after}}
+}
+
+// findOptions sorts by the requested field with _id as tie breaker, so
+// cursors address a stable order.
+func (p page) findOptions() *options.FindOptions {
+	direction := 1
+	if p.Descending {
+		direction = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: p.Sort_field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(p.Limit)
+	if p.Cursor == nil {
+		opts.SetSkip(p.Offset)
+	}
+	if projection := p.projection(); projection != nil {
+		opts.SetProjection(projection)
+	}
+	return opts
+}
+
+// projection loads only the stored fields behind the requested ones, plus
+// the sort field the next cursor is built from. It is nil when every field
+// is wanted.
+func (p page) projection() bson.M {
+	if len(p.Fields) == 0 {
+		return nil
+	}
+	projection := bson.M{}
+	for _, field := range p.Fields {
+		for _, stored := range bookFields[field] {
+			projection[stored] = 1
+		}
+	}
+	if p.Sort_field != "" {
+		projection[p.Sort_field] = 1
+	}
+	return projection
+}
+
+// nextCursor returns the cursor for the page after books, or "" when books
+// is the last page or the listing is not sorted by a field.
+func (p page) nextCursor(books []models.Books) (string, error) {
+	if p.Sort_field == "" || int64(len(books)) < p.Limit {
+		return "", nil
+	}
+	last := books[len(books)-1]
+	var value any
+	switch p.Sort_field {
+	case "price":
+		value = last.Price
+	case "name":
+		value = last.Name
+	case "sold_count":
+		value = last.Sold_count
+	default:
+		value = last.Created_at
+	}
+	raw, err := bson.Marshal(bson.M{"s": p.sort(), "v": value, "id": last.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// response builds the listing body: the books, limited to the requested
+// fields, and paging metadata. Books are expected to be loaded with
+// p.projection, so the fields left out here were never read.
+func (p page) response(books []models.Books, total int64) (gin.H, error) {
+	data := make([]any, 0, len(books))
+	for _, book := range books {
+		book = book.WithAvailability()
+		if len(p.Fields) == 0 {
+			data = append(data, book)
+			continue
+		}
+		projected, err := projectBook(book, p.Fields)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, projected)
+	}
+
+	body := gin.H{"data": data, "total": total, "limit": p.Limit}
+	if p.Cursor == nil {
+		body["offset"] = p.Offset
+	}
+	next, err := p.nextCursor(books)
+	if err != nil {
+		return nil, err
+	}
+	if next != "" {
+		body["next_cursor"] = next
+	}
+	return body, nil
+}
+
+func projectBook(book models.Books, fields []string) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(book)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &all); err != nil {
+		return nil, err
+	}
+	projected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		projected[field] = all[field]
+	}
+	return projected, nil
+}
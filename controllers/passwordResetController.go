@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/lockout"
+	"github.com/SHUBHAM91285/online_book_store/mailer"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/tokens"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const passwordResetTTL = time.Hour
+
+var mail mailer.Mailer = mailerFromEnv()
+var appBaseURL = baseURLFromEnv()
+var passwordResetURL = passwordResetURLFromEnv()
+
+// passwordResetGuard limits how often resets are requested for one email or
+// from one address, so the endpoint cannot be used to flood inboxes.
+var passwordResetGuard = lockout.NewThrottle(loginAttemptStore, "password_reset:",
+	lockout.Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Hour,
+		LockoutThreshold: 10,
+		LockoutDuration:  24 * time.Hour,
+	},
+	lockout.Policy{
+		FreeAttempts:     20,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Hour,
+		LockoutThreshold: 100,
+		LockoutDuration:  24 * time.Hour,
+	},
+)
+
+// mailerFromEnv stops the server when no mailer is configured, rather than
+// dropping reset links or writing them to the log.
+func mailerFromEnv() mailer.Mailer {
+	m, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return m
+}
+
+func baseURLFromEnv() string {
+	if baseURL := os.Getenv("APP_BASE_URL"); baseURL != "" {
+		return baseURL
+	}
+	return "http://localhost:8080"
+}
+
+// passwordResetURLFromEnv returns the page that reset emails link to, with the
+// token appended as ?token=. Stores with their own frontend point
+// PASSWORD_RESET_URL at it; otherwise the link opens the form served by
+// PasswordResetForm.
+func passwordResetURLFromEnv() string {
+	if resetURL := os.Getenv("PASSWORD_RESET_URL"); resetURL != "" {
+		return resetURL
+	}
+	return appBaseURL + "/user/password/reset"
+}
+
+func RequestPasswordReset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Email string `json:"email" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Requests count whether or not the account exists, for the same
+		// reason the response does not tell.
+		wait, err := passwordResetGuard.Check(ctx, request.Email, c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if wait > 0 {
+			c.Header("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many password reset requests, try again later"})
+			return
+		}
+		if err := passwordResetGuard.Failure(ctx, request.Email, c.ClientIP()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		// The response is the same whether or not the account exists, so the
+		// endpoint cannot be used to discover registered emails.
+		response := gin.H{"message": "if the account exists, a password reset link has been sent"}
+
+		var foundUser models.User
+		if err := userCollection.FindOne(ctx, bson.M{"email": request.Email}).Decode(&foundUser); err != nil {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		resetToken, err := tokens.IssueActionToken(ctx, tokens.PasswordResetPurpose, foundUser.Email, passwordResetTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		err = mail.Send(ctx, mailer.Message{
+			To:      foundUser.Email,
+			Subject: "Reset your password",
+			Body: "Hi " + foundUser.Name + ",\n\nUse the link below to choose a new password. It expires in one hour.\n\n" +
+				resetLink(resetToken) +
+				"\n\nIf you did not ask for a password reset you can ignore this email.",
+		})
+		if err != nil {
+			log.Println("failed to send password reset email:", err)
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// resetLink appends the reset token to passwordResetURL, keeping any query
+// the configured URL already has.
+func resetLink(token string) string {
+	link, err := url.Parse(passwordResetURL)
+	if err != nil {
+		return passwordResetURL + "?token=" + url.QueryEscape(token)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// passwordResetForm asks for the new password and submits it together with
+// the token from the link to ConfirmPasswordReset.
+const passwordResetForm = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reset your password</title></head>
+<body>
+<h1>Reset your password</h1>
+<form id="reset">
+<label>New password <input type="password" name="password" required></label>
+<button type="submit">Reset password</button>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("reset").addEventListener("submit", async function (event) {
+	event.preventDefault();
+	const response = await fetch("/user/password/reset/confirm", {
+		method: "POST",
+		headers: {"Content-Type": "application/json"},
+		body: JSON.stringify({
+			token: new URLSearchParams(location.search).get("token"),
+			password: this.password.value,
+		}),
+	});
+	const body = await response.json();
+	document.getElementById("result").textContent = body.message || body.error;
+});
+</script>
+</body>
+</html>
+`
+
+// PasswordResetForm serves the page reset emails link to when no
+// PASSWORD_RESET_URL is configured.
+func PasswordResetForm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Referrer-Policy", "no-referrer")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(passwordResetForm))
+	}
+}
+
+func ConfirmPasswordReset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Token    string `json:"token" validate:"required"`
+			Password string `json:"password" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		email, err := tokens.ConsumeActionToken(ctx, tokens.PasswordResetPurpose, request.Token)
+		if err == tokens.ErrInvalidActionToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		hashedPassword := HashPassword(request.Password)
+		result, err := userCollection.UpdateOne(ctx, bson.M{"email": email}, bson.M{"$set": bson.M{"password": hashedPassword}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error updating password"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		if err := tokens.RevokeAllSessions(ctx, email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "password updated but existing sessions could not be revoked"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "password has been reset, please login again"})
+	}
+}
@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/inventory"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/orders"
+	"github.com/SHUBHAM91285/online_book_store/payments"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxWebhookSize = 1 << 20
+
+var errPaymentSuperseded = errors.New("payment attempt was superseded")
+
+var errPaymentAmountMismatch = errors.New("paid amount does not match the order total")
+
+// paymentClaimTTL bounds how long a webhook delivery may hold an order's
+// payment attempt, so a claim left behind by a crash does not block the
+// provider's retries for good.
+const paymentClaimTTL = 5 * time.Minute
+
+var paymentProvider payments.PaymentProvider = paymentProviderFromEnv()
+var paymentCurrency = paymentCurrencyFromEnv()
+
+// paymentProviderFromEnv returns the gateway named by PAYMENT_PROVIDER. Only
+// the fake gateway exists so far; it delivers its webhooks to
+// PAYMENT_WEBHOOK_URL, this server's own webhook endpoint by default. An
+// unknown provider stops the server rather than taking orders it cannot
+// charge for.
+func paymentProviderFromEnv() payments.PaymentProvider {
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "":
+		log.Println("PAYMENT_PROVIDER is not set, using the fake payment gateway")
+	case "fake":
+	default:
+		log.Fatal("unknown PAYMENT_PROVIDER ", provider)
+	}
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		// The fake both signs and verifies, so a per-process secret works.
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			log.Fatal(err)
+		}
+		secret = hex.EncodeToString(b)
+	}
+	webhookURL := os.Getenv("PAYMENT_WEBHOOK_URL")
+	if webhookURL == "" {
+		webhookURL = appBaseURL + "/payments/webhook"
+	}
+	return payments.NewFakeProvider(secret, webhookURL)
+}
+
+func paymentCurrencyFromEnv() string {
+	if currency := os.Getenv("PAYMENT_CURRENCY"); currency != "" {
+		return currency
+	}
+	return "USD"
+}
+
+// PayOrder starts a payment attempt for one of the customer's placed orders.
+// An attempt that is still open is returned again instead of starting
+// another one, so retries do not leave stray authorizations behind.
+func PayOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		order, ok := findCustomerOrder(ctx, c)
+		if !ok {
+			return
+		}
+		if order.Status != models.OrderPlaced {
+			c.JSON(http.StatusConflict, gin.H{"error": "only placed orders can be paid"})
+			return
+		}
+
+		if order.Payment_intent_id != "" {
+			intent, err := paymentProvider.Intent(ctx, order.Payment_intent_id)
+			if err != nil && !errors.Is(err, payments.ErrIntentNotFound) {
+				c.JSON(http.StatusBadGateway, gin.H{"error": "failed to create payment"})
+				return
+			}
+			if err == nil && intent.Status != payments.IntentFailed && intent.Status != payments.IntentCanceled {
+				c.JSON(http.StatusOK, intent)
+				return
+			}
+		}
+
+		intent, err := paymentProvider.CreateIntent(ctx, order.ID.Hex(), order.Total, paymentCurrency)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to create payment"})
+			return
+		}
+		// Only replace the attempt this request found, so of two requests
+		// racing to pay the same order one keeps its intent and the other
+		// voids its own. Orders placed before payments existed have no
+		// intent field at all.
+		var previous interface{} = order.Payment_intent_id
+		if order.Payment_intent_id == "" {
+			previous = bson.M{"$in": bson.A{nil, ""}}
+		}
+		result, err := ordersCollection.UpdateOne(ctx,
+			bson.M{"_id": order.ID, "status": models.OrderPlaced, "payment_intent_id": previous},
+			bson.M{
+				"$set":   bson.M{"payment_intent_id": intent.ID, "updated_at": time.Now()},
+				"$unset": bson.M{"payment_error": ""},
+			},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			err = errPaymentSuperseded
+		}
+		if err != nil {
+			voidIntent(intent.ID)
+			if errors.Is(err, errPaymentSuperseded) {
+				c.JSON(http.StatusConflict, gin.H{"error": "the order is already being paid or is no longer placed"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create payment"})
+			return
+		}
+		c.JSON(http.StatusOK, intent)
+	}
+}
+
+// ConfirmPayment authorizes the order's current payment attempt. The order
+// becomes paid once the provider's webhook confirms it.
+func ConfirmPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		order, ok := findCustomerOrder(ctx, c)
+		if !ok {
+			return
+		}
+		if order.Status != models.OrderPlaced || order.Payment_intent_id == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "order has no pending payment"})
+			return
+		}
+		intent, err := paymentProvider.Confirm(ctx, order.Payment_intent_id)
+		if errors.Is(err, payments.ErrIntentNotFound) || errors.Is(err, payments.ErrInvalidState) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to confirm payment"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "payment submitted, the order is marked paid once the provider confirms it",
+			"intent":  intent,
+		})
+	}
+}
+
+// PaymentWebhook receives the provider's signed notifications. It is the only
+// way an order becomes paid.
+func PaymentWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookSize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read webhook"})
+			return
+		}
+		event, err := paymentProvider.VerifyWebhook(payload, c.GetHeader(payments.SignatureHeader))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook"})
+			return
+		}
+
+		switch event.Type {
+		case payments.EventPaymentAuthorized:
+			if err := markOrderPaid(ctx, event); err != nil {
+				log.Println("failed to process payment webhook", event.ID+":", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process webhook"})
+				return
+			}
+		case payments.EventPaymentFailed:
+			log.Println("payment", event.Intent_id, "for order", event.Order_id, "failed")
+		}
+		c.JSON(http.StatusOK, gin.H{"received": true})
+	}
+}
+
+// markOrderPaid captures an authorized payment and moves its order to paid.
+// Webhooks may be delivered more than once, even concurrently, so a delivery
+// first claims the order's payment attempt. Events for orders that are
+// already past placed, for superseded payment attempts, or for attempts
+// another delivery is working on match nothing and are ignored.
+func markOrderPaid(ctx context.Context, event payments.Event) error {
+	orderID, err := primitive.ObjectIDFromHex(event.Order_id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var order models.Order
+	err = ordersCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":                   orderID,
+			"status":                models.OrderPlaced,
+			"payment_intent_id":     event.Intent_id,
+			"payment_claimed_until": bson.M{"$not": bson.M{"$gt": now}},
+		},
+		bson.M{"$set": bson.M{"payment_claimed_until": now.Add(paymentClaimTTL)}},
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// A failed delivery gives up its claim so the provider's retry can
+	// take it; if the process dies instead, the claim lapses on its own.
+	paid := false
+	defer func() {
+		if !paid {
+			releasePaymentClaim(order.ID)
+		}
+	}()
+	if event.Amount != order.Total {
+		// Retrying cannot fix the amount. The order stays placed, so the
+		// customer can pay it again, and staff can see what went wrong.
+		log.Println("payment", event.Intent_id, "for order", order.ID.Hex(), "authorized", event.Amount, "instead of", order.Total)
+		recordOrderError(order.ID, "payment_error", errPaymentAmountMismatch)
+		voidIntent(event.Intent_id)
+		return nil
+	}
+
+	// Stock is settled before the money is taken. A reservation committed
+	// by an earlier delivery that did not get to capture is already
+	// settled. If the reservation ran out, or the order predates
+	// reservations, the copies may still be there; if not, the order is
+	// cancelled and its authorization voided.
+	lines := inventory.LinesFor(order.Items)
+	unsettle := func(ctx context.Context) error { return inventory.Uncommit(ctx, order.ID) }
+	err = inventory.Commit(ctx, order.ID)
+	switch {
+	case errors.Is(err, inventory.ErrReservationCommitted):
+		err = nil
+	case errors.Is(err, inventory.ErrReservationNotActive):
+		unsettle = func(ctx context.Context) error { return inventory.Restock(ctx, lines) }
+		err = inventory.Decrement(ctx, lines)
+		var insufficient *inventory.InsufficientStockError
+		if errors.As(err, &insufficient) {
+			log.Println("order", order.ID.Hex(), "was paid after its stock ran out:", err)
+			return cancelUnfulfillableOrder(ctx, order.ID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := paymentProvider.Capture(ctx, event.Intent_id); err != nil {
+		// The provider retries the webhook, which settles the stock again.
+		undoPayment(order.ID, unsettle)
+		return err
+	}
+	_, err = orders.Transition(ctx, order.ID, models.OrderPaid, "payment:"+paymentProvider.Name(), "payment "+event.Intent_id)
+	var illegal *orders.IllegalTransitionError
+	if errors.Is(err, orders.ErrConflict) || errors.As(err, &illegal) {
+		// The order was cancelled while the payment went through.
+		log.Println("order", order.ID.Hex(), "was cancelled while being paid, refunding", event.Intent_id)
+		undoPayment(order.ID, func(ctx context.Context) error {
+			if _, err := paymentProvider.Refund(ctx, event.Intent_id, order.Total); err != nil {
+				return err
+			}
+			return unsettle(ctx)
+		})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	paid = true
+	return nil
+}
+
+// cancelUnfulfillableOrder cancels a placed order whose copies are gone and
+// voids its payment. An order that has already moved on is left alone.
+func cancelUnfulfillableOrder(ctx context.Context, orderID primitive.ObjectID) error {
+	order, err := orders.Transition(ctx, orderID, models.OrderCancelled, "system", "out of stock when paid")
+	var illegal *orders.IllegalTransitionError
+	if errors.Is(err, orders.ErrConflict) || errors.As(err, &illegal) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	voidPayment(order)
+	return nil
+}
+
+// releasePaymentClaim lets another webhook delivery process the order's
+// payment.
+func releasePaymentClaim(orderID primitive.ObjectID) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	_, err := ordersCollection.UpdateOne(ctx,
+		bson.M{"_id": orderID},
+		bson.M{"$unset": bson.M{"payment_claimed_until": ""}},
+	)
+	if err != nil {
+		log.Println("failed to release payment claim of order", orderID.Hex()+":", err)
+	}
+}
+
+// undoPayment runs undo for a payment that could not be completed.
+func undoPayment(orderID primitive.ObjectID, undo func(ctx context.Context) error) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	if err := undo(ctx); err != nil {
+		log.Println("failed to undo payment of order", orderID.Hex()+":", err)
+	}
+}
+
+// voidPayment cancels the payment attempt of an order that was cancelled, so
+// the customer's funds are not left authorized.
+func voidPayment(order models.Order) {
+	if order.Payment_intent_id != "" {
+		voidIntent(order.Payment_intent_id)
+	}
+}
+
+// voidIntent cancels a payment intent.
+func voidIntent(intentID string) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	if _, err := paymentProvider.Cancel(ctx, intentID); err != nil {
+		log.Println("failed to cancel payment", intentID+":", err)
+	}
+}
+
+// refundOrder returns the money for a paid order through the provider.
+func refundOrder(ctx context.Context, order models.Order) error {
+	if order.Payment_intent_id == "" {
+		return nil
+	}
+	_, err := paymentProvider.Refund(ctx, order.Payment_intent_id, order.Total)
+	return err
+}
+
+// recordOrderError notes on an order why its payment or refund did not go
+// through, so staff can settle it with the provider by hand.
+func recordOrderError(orderID primitive.ObjectID, field string, orderErr error) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	_, err := ordersCollection.UpdateOne(ctx,
+		bson.M{"_id": orderID},
+		bson.M{"$set": bson.M{field: orderErr.Error(), "updated_at": time.Now()}},
+	)
+	if err != nil {
+		log.Println("failed to record", field, "of order", orderID.Hex()+":", err)
+	}
+}
+
+// findCustomerOrder loads the order named by the :id parameter if it belongs
+// to the current user, answering the request itself when it does not.
+func findCustomerOrder(ctx context.Context, c *gin.Context) (models.Order, bool) {
+	orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return models.Order{}, false
+	}
+	var order models.Order
+	err = ordersCollection.FindOne(ctx, bson.M{"_id": orderID, "user_id": middleware.CurrentUser(c).ID}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return models.Order{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load order"})
+		return models.Order{}, false
+	}
+	return order, true
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/publishers"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var publishersCollection *mongo.Collection = database.OpenCollection(database.Client, "publishers")
+
+func GetPublisher() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("publisher_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid publisher ID"})
+			return
+		}
+		publisher, err := publishers.Find(ctx, objID)
+		if err == publishers.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading publisher"})
+			return
+		}
+		c.JSON(http.StatusOK, publisher)
+	}
+}
+
+// GetPublisherBooks lists the books of a publisher, paged like GetBooks.
+func GetPublisherBooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("publisher_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid publisher ID"})
+			return
+		}
+		p, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := publishers.Find(ctx, objID); err == publishers.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading publisher"})
+			return
+		}
+		body, err := listBooks(ctx, p, bson.M{"publisher_id": objID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing books"})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+func AddPublisher() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var publisher models.Publisher
+		if err := c.BindJSON(&publisher); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(publisher); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		publisher.ID = primitive.NewObjectID()
+		publisher.Created_at = time.Now()
+		publisher.Updated_at = publisher.Created_at
+		_, err := publishersCollection.InsertOne(ctx, publisher)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a publisher with this name already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "publisher is not created"})
+			return
+		}
+		c.JSON(http.StatusCreated, publisher)
+	}
+}
+
+// UpdatePublisher changes a publisher's details, renaming its books'
+// Publication along with it.
+func UpdatePublisher() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("publisher_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid publisher ID"})
+			return
+		}
+		var request struct {
+			Name    *string `json:"name"`
+			Country *string `json:"country"`
+			Website *string `json:"website" validate:"omitempty,url"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		update := bson.M{"updated_at": time.Now()}
+		if request.Name != nil {
+			if *request.Name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+				return
+			}
+			update["name"] = *request.Name
+		}
+		if request.Country != nil {
+			update["country"] = *request.Country
+		}
+		if request.Website != nil {
+			update["website"] = *request.Website
+		}
+
+		var publisher models.Publisher
+		err = publishersCollection.FindOneAndUpdate(ctx,
+			bson.M{"_id": objID},
+			bson.M{"$set": update},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&publisher)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "publisher not found"})
+			return
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a publisher with this name already exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "publisher update failed"})
+			return
+		}
+		if request.Name != nil {
+			if err := publishers.Refresh(ctx, objID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "publisher updated, but not all of its books were"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, publisher)
+	}
+}
+
+// DeletePublisher deletes a publisher without books.
+func DeletePublisher() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		objID, err := primitive.ObjectIDFromHex(c.Param("publisher_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid publisher ID"})
+			return
+		}
+		hasBooks, err := publishers.HasBooks(ctx, objID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete publisher"})
+			return
+		}
+		if hasBooks {
+			c.JSON(http.StatusConflict, gin.H{"error": "publisher still has books; merge it into another publisher instead"})
+			return
+		}
+		result, err := publishersCollection.DeleteOne(ctx, bson.M{"_id": objID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete publisher"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "publisher not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "publisher deleted successfully"})
+	}
+}
+
+// MergePublishers moves the books of duplicate publishers to the publisher
+// they duplicate, and deletes the duplicates.
+func MergePublishers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Into primitive.ObjectID   `json:"into" validate:"required"`
+			From []primitive.ObjectID `json:"from" validate:"required,min=1"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		moved, err := publishers.Merge(ctx, request.Into, request.From)
+		switch {
+		case err == publishers.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case err == publishers.ErrSelfMerge:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "publisher merge failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"books_moved": moved})
+	}
+}
+
+// linkPublisher checks the publisher of book and copies its name into
+// Publication. It answers the request and returns false if there is no
+// such publisher.
+func linkPublisher(ctx context.Context, c *gin.Context, book *models.Books) bool {
+	publisher, err := publishers.Find(ctx, *book.Publisher_id)
+	if err == publishers.ErrNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading publisher"})
+		return false
+	}
+	book.Publication = publisher.Name
+	return true
+}
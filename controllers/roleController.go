@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func ListRoles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles := []gin.H{}
+		for _, role := range rbac.Roles() {
+			roles = append(roles, gin.H{
+				"name":        role,
+				"permissions": rbac.PermissionsOf(role),
+			})
+		}
+		c.JSON(http.StatusOK, roles)
+	}
+}
+
+func AssignRoles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Roles []string `json:"roles" validate:"required,min=1"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, role := range request.Roles {
+			if !rbac.IsValid(role) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role " + role})
+				return
+			}
+		}
+
+		userID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		// Never leave the store without someone able to hand out roles.
+		if !rbac.HasPermission(request.Roles, rbac.ManageUsers) {
+			remaining, err := userCollection.CountDocuments(ctx, bson.M{
+				"_id":   bson.M{"$ne": userID},
+				"roles": rbac.SuperAdmin,
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking admin count"})
+				return
+			}
+			if remaining == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "at least one super admin must remain"})
+				return
+			}
+		}
+
+		result, err := userCollection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"roles": request.Roles}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign roles"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "roles updated successfully",
+			"assigned_by": middleware.CurrentUser(c).Email,
+			"roles":       request.Roles,
+			"permissions": rbac.Permissions(request.Roles),
+		})
+	}
+}
+
+// MigrateRoles backfills roles for accounts created before role-based access
+// control, which only carry the legacy role string. Former admins become
+// super admins and everyone else a customer; the legacy field is then
+// dropped so the migration is a no-op on later startups.
+func MigrateRoles(ctx context.Context) error {
+	legacy := bson.M{"role": bson.M{"$exists": true}, "roles": bson.M{"$exists": false}}
+	admins := bson.M{"$and": bson.A{legacy, bson.M{"role": "admin"}}}
+	if _, err := userCollection.UpdateMany(ctx, admins, bson.M{"$set": bson.M{"roles": []string{string(rbac.SuperAdmin)}}}); err != nil {
+		return err
+	}
+	if _, err := userCollection.UpdateMany(ctx, legacy, bson.M{"$set": bson.M{"roles": []string{string(rbac.Customer)}}}); err != nil {
+		return err
+	}
+	_, err := userCollection.UpdateMany(ctx, bson.M{"role": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"role": ""}})
+	return err
+}
+
+// BootstrapSuperAdmin makes the account registered under SUPER_ADMIN_EMAIL a
+// super admin while the store has none, so a fresh deployment can hand out
+// staff roles. The account has to sign up first; until it does, or once any
+// super admin exists, this does nothing.
+func BootstrapSuperAdmin(ctx context.Context) error {
+	email := os.Getenv("SUPER_ADMIN_EMAIL")
+	if email == "" {
+		return nil
+	}
+	count, err := userCollection.CountDocuments(ctx, bson.M{"roles": rbac.SuperAdmin})
+	if err != nil || count > 0 {
+		return err
+	}
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"email": email},
+		bson.M{"$addToSet": bson.M{"roles": rbac.SuperAdmin}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		log.Println("SUPER_ADMIN_EMAIL", email, "has not signed up yet; no super admin was created")
+	}
+	return nil
+}
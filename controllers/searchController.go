@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/search"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxSearchHits bounds how many books a free-text query can match.
+const maxSearchHits = 1000
+
+var bookSearch search.SearchIndex = searchIndexFromEnv()
+
+// searchIndexFromEnv uses the Mongo text index unless SEARCH_BACKEND is
+// "bleve", in which case the embedded index is kept at SEARCH_INDEX_PATH, or
+// in memory when that is unset.
+func searchIndexFromEnv() search.SearchIndex {
+	if os.Getenv("SEARCH_BACKEND") == "bleve" {
+		index, err := search.NewBleveIndex(os.Getenv("SEARCH_INDEX_PATH"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return index
+	}
+	return search.NewMongoIndex(booksCollection)
+}
+
+// searchFields maps the text query parameters of /books/search to the
+// fields they match. Word filters are looked up in the search index, the
+// others must equal the field exactly.
+var searchFields = []struct {
+	Param string
+	Field string
+	Words bool
+}{
+	{"name", "name", true},
+	{"author", "author_name", true},
+	{"genre", "genre", true},
+	{"description", "description", true},
+	{"publication", "publication", false},
+}
+
+// SearchBooks lists the books matching the field filters in the query. The
+// name, author, genre and description filters match whole words of their
+// field regardless of case; publication takes the exact value and category
+// the id the facets report. Filters are combined with AND unless match=any is
+// given, while price and publication date ranges always apply. A free-text
+// query in q further narrows the results, which are then ranked by relevance
+// unless a sort is given. Results are paged like GetBooks; with facets=true
+// the response also counts every matching book by genre, category,
+// publication, author and price. A free-text query that matches nothing gets
+// did_you_mean suggestions.
+func SearchBooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter, err := searchFilter(ctx, c)
+		if err != nil {
+			respondSearchFilterError(c, err)
+			return
+		}
+		p, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		text := c.Query("q")
+		if text != "" && c.Query("sort") == "" {
+			if p.Cursor != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "results ranked by relevance are paged by offset"})
+				return
+			}
+			p.Sort_field = ""
+		}
+
+		var hits []search.Hit
+		if text != "" {
+			hits, err = bookSearch.Search(ctx, text, maxSearchHits)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while searching books"})
+				return
+			}
+			ids := make([]primitive.ObjectID, len(hits))
+			for i, hit := range hits {
+				ids[i] = hit.ID
+			}
+			filter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$in": ids}}}}
+		}
+
+		var body gin.H
+		if p.Sort_field == "" {
+			body, err = rankBooks(ctx, p, filter, hits)
+		} else {
+			body, err = listBooks(ctx, p, filter)
+		}
+		if err == nil && c.Query("facets") == "true" {
+			body["facets"], err = bookFacets(ctx, filter)
+		}
+		if err == nil && text != "" && body["total"] == int64(0) {
+			body["did_you_mean"] = didYouMean(text)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while searching books"})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+// errSearchUnavailable is returned by searchFilter when the search index
+// fails, as opposed to the query being invalid.
+var errSearchUnavailable = errors.New("error occured while searching books")
+
+// searchFilter builds the Mongo filter for the search query parameters.
+// Every condition can be narrowed down by an index: word filters by the
+// search index, whose hits are then checked for the words in the filter's
+// own field, the exact filters, category and date ranges by their field
+// indexes and price ranges by the price sort index.
+func searchFilter(ctx context.Context, c *gin.Context) (bson.M, error) {
+	var matches bson.A
+	for _, f := range searchFields {
+		value := c.Query(f.Param)
+		if value == "" {
+			continue
+		}
+		if !f.Words {
+			matches = append(matches, bson.M{f.Field: value})
+			continue
+		}
+		hits, err := bookSearch.Search(ctx, value, maxSearchHits)
+		if err != nil {
+			log.Println("failed to search books for the", f.Param, "filter:", err)
+			return nil, errSearchUnavailable
+		}
+		ids := make([]primitive.ObjectID, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.ID
+		}
+		matches = append(matches, bson.M{
+			"_id":   bson.M{"$in": ids},
+			f.Field: primitive.Regex{Pattern: regexp.QuoteMeta(value), Options: "i"},
+		})
+	}
+
+	if value := c.Query("category"); value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, errors.New("category must be a category id")
+		}
+		matches = append(matches, bson.M{"category_ids": id})
+	}
+
+	var clauses bson.A
+	switch c.DefaultQuery("match", "all") {
+	case "all":
+		clauses = append(clauses, matches...)
+	case "any":
+		if len(matches) > 0 {
+			clauses = append(clauses, bson.M{"$or": matches})
+		}
+	default:
+		return nil, errors.New("match must be all or any")
+	}
+
+	price := bson.M{}
+	for param, operator := range map[string]string{"price_min": "$gte", "price_max": "$lte"} {
+		if value := c.Query(param); value != "" {
+			amount, err := strconv.Atoi(value)
+			if err != nil || amount < 0 {
+				return nil, errors.New(param + " must be a non-negative number")
+			}
+			price[operator] = amount
+		}
+	}
+	if len(price) > 0 {
+		clauses = append(clauses, bson.M{"price": price})
+	}
+
+	published := bson.M{}
+	for param, operator := range map[string]string{"published_from": "$gte", "published_to": "$lte"} {
+		if value := c.Query(param); value != "" {
+			at, err := parseSearchDate(value, operator == "$lte")
+			if err != nil {
+				return nil, errors.New(param + " must be a date such as 2006-01-02 or an RFC 3339 timestamp")
+			}
+			published[operator] = at
+		}
+	}
+	if len(published) > 0 {
+		clauses = append(clauses, bson.M{"published_at": published})
+	}
+
+	switch len(clauses) {
+	case 0:
+		return bson.M{}, nil
+	case 1:
+		return clauses[0].(bson.M), nil
+	}
+	return bson.M{"$and": clauses}, nil
+}
+
+// respondSearchFilterError answers a request whose search filters could not
+// be built.
+func respondSearchFilterError(c *gin.Context, err error) {
+	if errors.Is(err, errSearchUnavailable) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// parseSearchDate accepts a bare date or an RFC 3339 timestamp. A bare date
+// used as an upper bound covers the whole day.
+func parseSearchDate(value string, endOfDay bool) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	at, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		at = at.Add(24*time.Hour - time.Nanosecond)
+	}
+	return at, nil
+}
+
+// rankBooks returns page p of the books matching filter, ordered like hits.
+// Books missing from hits are left out.
+func rankBooks(ctx context.Context, p page, filter bson.M, hits []search.Hit) (gin.H, error) {
+	opts := options.Find()
+	if projection := p.projection(); projection != nil {
+		opts.SetProjection(projection)
+	}
+	cursor, err := booksCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var matches []models.Books
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.Books, len(matches))
+	for _, book := range matches {
+		byID[book.ID] = book
+	}
+	ranked := make([]models.Books, 0, len(matches))
+	for _, hit := range hits {
+		if book, ok := byID[hit.ID]; ok {
+			ranked = append(ranked, book)
+		}
+	}
+
+	start := min(p.Offset, int64(len(ranked)))
+	end := min(start+p.Limit, int64(len(ranked)))
+	return p.response(ranked[start:end], int64(len(ranked)))
+}
+
+// indexBook adds book to the search index. The book is already saved, so a
+// failure is only logged; the index catches up when the book next changes
+// or the server restarts.
+func indexBook(ctx context.Context, book models.Books) {
+	if err := bookSearch.Index(ctx, book); err != nil {
+		log.Println("failed to index book", book.ID.Hex()+":", err)
+	}
+}
+
+// reindexBook reloads the book with id and indexes it again.
+func reindexBook(ctx context.Context, id primitive.ObjectID) {
+	var book models.Books
+	if err := booksCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&book); err != nil {
+		log.Println("failed to reload book", id.Hex(), "for the search index:", err)
+		return
+	}
+	indexBook(ctx, book)
+}
+
+// indexBooks indexes every book matching filter, for search indexes that
+// keep their own copy of the catalogue.
+func indexBooks(ctx context.Context, filter bson.M) error {
+	if _, ok := bookSearch.(*search.MongoIndex); ok {
+		return nil
+	}
+	cursor, err := booksCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var book models.Books
+		if err := cursor.Decode(&book); err != nil {
+			return err
+		}
+		if err := bookSearch.Index(ctx, book); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/suggest"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	suggestionRefreshInterval = time.Minute
+	maxDidYouMean             = 3
+)
+
+var bookSuggestions suggest.Index
+
+// SuggestBooks completes the title, author or genre being typed in q. It is
+// answered from memory, so it can be called on every keystroke.
+func SuggestBooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prefix := c.Query("q")
+		if prefix == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+			return
+		}
+		limit := suggest.MaxCompletions
+		if value := c.Query("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > suggest.MaxCompletions {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(suggest.MaxCompletions)})
+				return
+			}
+			limit = n
+		}
+
+		suggestions := bookSuggestions.Complete(prefix, limit)
+		if suggestions == nil {
+			suggestions = []suggest.Suggestion{}
+		}
+		c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+	}
+}
+
+// didYouMean returns the known titles, authors and genres closest to text,
+// for searches that found nothing.
+func didYouMean(text string) []suggest.Suggestion {
+	suggestions := bookSuggestions.Correct(text, suggest.MaxDistance(text), maxDidYouMean)
+	if suggestions == nil {
+		suggestions = []suggest.Suggestion{}
+	}
+	return suggestions
+}
+
+// StartSuggestionRefresher loads the suggestion trie from the books
+// collection now and then again every suggestionRefreshInterval.
+func StartSuggestionRefresher() {
+	if err := refreshSuggestions(); err != nil {
+		log.Println("failed to load search suggestions:", err)
+	}
+	go func() {
+		for range time.Tick(suggestionRefreshInterval) {
+			if err := refreshSuggestions(); err != nil {
+				log.Println("failed to refresh search suggestions:", err)
+			}
+		}
+	}()
+}
+
+func refreshSuggestions() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := booksCollection.Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"name": 1, "authors.name": 1, "genre": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []suggest.Entry
+	for cursor.Next(ctx) {
+		var book struct {
+			Name    string              `bson:"name"`
+			Authors []models.BookAuthor `bson:"authors"`
+			Genre   string              `bson:"genre"`
+		}
+		if err := cursor.Decode(&book); err != nil {
+			return err
+		}
+		entries = append(entries,
+			suggest.Entry{Text: book.Name, Kind: suggest.Title},
+			suggest.Entry{Text: book.Genre, Kind: suggest.Genre},
+		)
+		// Each author is suggested on their own, not as the joined byline.
+		for _, author := range book.Authors {
+			entries = append(entries, suggest.Entry{Text: author.Name, Kind: suggest.Author})
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	bookSuggestions.Replace(suggest.Build(entries))
+	return nil
+}
@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
+	"github.com/SHUBHAM91285/online_book_store/tokens"
+	"github.com/SHUBHAM91285/online_book_store/totp"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer        = "Online Book Store"
+	recoveryCodeCount = 10
+)
+
+func EnrollTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		foundUser := middleware.CurrentUser(c)
+		if foundUser.Two_factor.Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"_id": foundUser.ID},
+			bson.M{"$set": bson.M{"two_factor.pending_secret": secret}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start two-factor enrollment"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"secret":      secret,
+			"otpauth_uri": totp.URI(totpIssuer, foundUser.Email, secret),
+			"message":     "add the account to your authenticator app and confirm with a code",
+		})
+	}
+}
+
+func ActivateTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Code string `json:"code" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		foundUser := middleware.CurrentUser(c)
+		if foundUser.Two_factor.Enabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+		if foundUser.Two_factor.Pending_secret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor enrollment has not been started"})
+			return
+		}
+		step, ok := totp.Validate(foundUser.Two_factor.Pending_secret, request.Code, time.Now())
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid two-factor code"})
+			return
+		}
+
+		recoveryCodes, hashedCodes, err := generateRecoveryCodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"_id": foundUser.ID},
+			bson.M{"$set": bson.M{"two_factor": models.TwoFactor{
+				Enabled:        true,
+				Secret:         foundUser.Two_factor.Pending_secret,
+				Last_step:      step,
+				Recovery_codes: hashedCodes,
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "two-factor authentication enabled, store the recovery codes somewhere safe",
+			"recovery_codes": recoveryCodes,
+		})
+	}
+}
+
+func DisableTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Code string `json:"code" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		foundUser := middleware.CurrentUser(c)
+		if rbac.IsStaff(foundUser.Roles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is mandatory for staff accounts"})
+			return
+		}
+		if !foundUser.Two_factor.Enabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is not enabled"})
+			return
+		}
+		ok, err := verifySecondFactor(ctx, foundUser, request.Code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid two-factor code"})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(ctx,
+			bson.M{"_id": foundUser.ID},
+			bson.M{"$set": bson.M{"two_factor": models.TwoFactor{}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+	}
+}
+
+// LoginTwoFactor is the second login step for accounts with two-factor
+// authentication. It exchanges the challenge token from Login and a TOTP or
+// recovery code for the real session tokens.
+func LoginTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request struct {
+			Challenge_token string `json:"challenge_token" validate:"required"`
+			Code            string `json:"code" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		claims, msg := tokens.VerifyChallengeToken(request.Challenge_token)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+		if !checkLoginAllowed(ctx, c, claims.Email) {
+			return
+		}
+		var foundUser models.User
+		if err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+		ok, err := verifySecondFactor(ctx, foundUser, request.Code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !ok {
+			if err := loginGuard.Failure(ctx, claims.Email, c.ClientIP()); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid two-factor code"})
+			return
+		}
+		if err := loginGuard.Success(ctx, foundUser.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+
+		if err := tokens.RevokeAccessToken(ctx, claims); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		tokenString, refreshToken, err := generateTokens(ctx, foundUser.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "you are welcomed",
+			"token":         tokenString,
+			"refresh_token": refreshToken,
+		})
+	}
+}
+
+// verifySecondFactor accepts either a current TOTP code or one of the
+// user's unused recovery codes. Both are single use: TOTP codes cannot be
+// replayed within their window and recovery codes are removed once used.
+func verifySecondFactor(ctx context.Context, user models.User, code string) (bool, error) {
+	if !user.Two_factor.Enabled {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(user.Two_factor.Secret, code, time.Now()); ok {
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "two_factor.last_step": bson.M{"$lt": step}},
+			bson.M{"$set": bson.M{"two_factor.last_step": step}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	for _, hashed := range user.Two_factor.Recovery_codes {
+		if bcrypt.CompareHashAndPassword([]byte(hashed), []byte(normalized)) != nil {
+			continue
+		}
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "two_factor.recovery_codes": hashed},
+			bson.M{"$pull": bson.M{"two_factor.recovery_codes": hashed}},
+		)
+		if err != nil {
+			return false, err
+		}
+		return result.ModifiedCount == 1, nil
+	}
+	return false, nil
+}
+
+// generateRecoveryCodes returns the codes to show the user once and the
+// bcrypt hashes to store in their place.
+func generateRecoveryCodes() (codes []string, hashed []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		code = code[:8] + "-" + code[8:]
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashed = append(hashed, string(hash))
+	}
+	return codes, hashed, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -2,12 +2,16 @@ package controllers
 
 import (
 	"context"
+	"io"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/isbn"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
 	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
 	"github.com/SHUBHAM91285/online_book_store/tokens"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -21,49 +25,65 @@ var userCollection *mongo.Collection = database.OpenCollection(database.Client,
 func SignUp() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		var user models.User
-
-		if err := c.BindJSON(&user); err != nil {
+		defer cancel()
+		// Roles are never taken from the signup payload: every account starts
+		// as a customer, and staff roles are handed out through the admin API
+		// by a super admin. The first super admin comes from SUPER_ADMIN_EMAIL.
+		var request struct {
+			Name     string `json:"name" validate:"required"`
+			Email    string `json:"email" validate:"required,email"`
+			Password string `json:"password" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		validationErr := validate.Struct(user)
+		validationErr := validate.Struct(request)
 		if validationErr != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
 			return
 		}
-		adminCount, err := userCollection.CountDocuments(ctx, bson.M{"role": "admin"})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking admin count"})
-			return
+		user := models.User{
+			Name:     request.Name,
+			Email:    request.Email,
+			Password: request.Password,
+			Roles:    []string{string(rbac.Customer)},
 		}
 
-		if adminCount > 0 && user.Role == "admin" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Only one admin user is allowed"})
-			return
-		}
-		err = userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&user)
+		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&models.User{})
 		if err == nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "user already exist"})
 			return
 		}
-		defer cancel()
 		user.ID = primitive.NewObjectID()
 		password := HashPassword(user.Password)
 		user.Password = password
-		tokenString, err := tokens.TokenGenerator(user.Email)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-			return
-		}
+		user.Email_verified = false
+		user.Verified_at = nil
 		userInfo, insertErr := userCollection.InsertOne(ctx, user)
 		if insertErr != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "User is not created"})
 			return
 		}
+		if err := sendVerificationEmail(ctx, user); err != nil {
+			log.Println("failed to send verification email:", err)
+		}
+		if middleware.EmailVerificationPolicy == middleware.VerifyBeforeLogin {
+			c.JSON(http.StatusOK, gin.H{
+				"userInfo": userInfo,
+				"message":  "please verify your email address before logging in",
+			})
+			return
+		}
+		tokenString, refreshToken, err := generateTokens(ctx, user.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
-			"userInfo": userInfo,
-			"token":    tokenString,
+			"userInfo":      userInfo,
+			"token":         tokenString,
+			"refresh_token": refreshToken,
 		})
 	}
 }
@@ -71,147 +91,242 @@ func SignUp() gin.HandlerFunc {
 func Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var user models.User
 		var foundUser models.User
 		if err := c.BindJSON(&user); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if !checkLoginAllowed(ctx, c, user.Email) {
+			return
+		}
 		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
-		defer cancel()
+		if err == mongo.ErrNoDocuments {
+			VerifyPassword(user.Password, dummyPasswordHash())
+			rejectLogin(ctx, c, user.Email)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found,login seems to be incorrect"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 			return
 		}
 		passwordIsValid := VerifyPassword(user.Password, foundUser.Password)
-		defer cancel()
 		if passwordIsValid != true {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "password is invalid"})
+			rejectLogin(ctx, c, user.Email)
+			return
+		}
+		if middleware.EmailVerificationPolicy == middleware.VerifyBeforeLogin && !foundUser.Email_verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "email address is not verified"})
+			return
+		}
+		if foundUser.Two_factor.Enabled {
+			// The account is only cleared once the second factor passed too.
+			challengeToken, err := tokens.ChallengeTokenGenerator(foundUser.Email)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"message":             "two-factor code required",
+				"two_factor_required": true,
+				"challenge_token":     challengeToken,
+			})
+			return
+		}
+		if err := loginGuard.Success(ctx, foundUser.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 			return
 		}
-		tokenString, err := tokens.TokenGenerator(foundUser.Email)
+		tokenString, refreshToken, err := generateTokens(ctx, foundUser.Email)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"message": "you are welcomed",
-			"token":   tokenString,
+			"message":       "you are welcomed",
+			"token":         tokenString,
+			"refresh_token": refreshToken,
 		})
 	}
 }
 
 func UserProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		foundUser := middleware.CurrentUser(c)
+
+		c.JSON(http.StatusOK, gin.H{
+			"name":           foundUser.Name,
+			"email":          foundUser.Email,
+			"email_verified": foundUser.Email_verified,
+			"two_factor":     foundUser.Two_factor.Enabled,
+			"roles":          foundUser.Roles,
+			"permissions":    rbac.Permissions(foundUser.Roles),
+			"cart":           foundUser.Cart,
+		})
+	}
+}
+
+func UpdatePassword() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		var foundUser models.User
+		defer cancel()
+		var user models.User
 
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		if err := c.BindJSON(&user); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		tokenString = tokenString[len("Bearer "):]
+		foundUser := middleware.CurrentUser(c)
 
-		claims, msg := tokens.VerifyToken(tokenString)
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		hashedPassword := HashPassword(user.Password)
+		update := bson.M{"$set": bson.M{"password": hashedPassword}}
+		_, err := userCollection.UpdateOne(ctx, bson.M{"email": foundUser.Email}, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error updating password"})
 			return
 		}
-		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
-		defer cancel()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		if err := tokens.RevokeAllSessions(ctx, foundUser.Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "password updated but existing sessions could not be revoked"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"name":  foundUser.Name,
-			"email": foundUser.Email,
-			"role":  foundUser.Role,
-			"cart":  foundUser.Cart,
-		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "password updated successfully, please login again"})
 	}
 }
 
-func UpdatePassword() gin.HandlerFunc {
+func RefreshToken() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		var foundUser models.User
-		var user models.User
+		defer cancel()
 
-		if err := c.BindJSON(&user); err != nil {
+		var request struct {
+			Refresh_token string `json:"refresh_token" validate:"required"`
+		}
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
+		email, refreshToken, err := tokens.RotateRefreshToken(ctx, request.Refresh_token)
+		if err == tokens.ErrInvalidRefreshToken {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 			return
 		}
-		tokenString = tokenString[len("Bearer "):]
-
-		claims, msg := tokens.VerifyToken(tokenString)
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		tokenString, err := tokens.TokenGenerator(email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 			return
 		}
-		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
+		c.JSON(http.StatusOK, gin.H{
+			"token":         tokenString,
+			"refresh_token": refreshToken,
+		})
+	}
+}
+
+func Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+
+		var request struct {
+			Refresh_token string `json:"refresh_token"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil && err != io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		hashedPassword := HashPassword(user.Password)
-		update := bson.M{"$set": bson.M{"password": hashedPassword}}
-		_, err = userCollection.UpdateOne(ctx, bson.M{"email": foundUser.Email}, update)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error updating password"})
+		if err := tokens.RevokeAccessToken(ctx, middleware.CurrentClaims(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
 			return
 		}
+		if request.Refresh_token != "" {
+			err := tokens.RevokeRefreshToken(ctx, request.Refresh_token)
+			if err != nil && err != tokens.ErrInvalidRefreshToken {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+	}
+}
 
-		c.JSON(http.StatusOK, gin.H{"message": "password updated successfully"})
+func LogoutAllSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := tokens.RevokeAllSessions(ctx, middleware.CurrentUser(c).Email); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout from all sessions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "logged out from all sessions"})
 	}
 }
 
 func AddBookToCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		var foundUser models.User
-		var book models.Books
+		defer cancel()
 		var foundBook models.Books
 		var cart models.Cart
+		var request struct {
+			Book_id string `json:"book_id"`
+			Isbn    string `json:"isbn"`
+		}
 
-		if err := c.BindJSON(&book); err != nil {
+		if err := c.BindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		foundUser := middleware.CurrentUser(c)
 
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		var filter bson.M
+		switch {
+		case request.Book_id != "":
+			bookID, err := primitive.ObjectIDFromHex(request.Book_id)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+				return
+			}
+			filter = bson.M{"_id": bookID}
+		case request.Isbn != "":
+			_, isbn13, err := isbn.Parse(request.Isbn)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			filter = bson.M{"isbn_13": isbn13}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "book_id or isbn is required"})
 			return
 		}
-		tokenString = tokenString[len("Bearer "):]
 
-		claims, msg := tokens.VerifyToken(tokenString)
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		err := booksCollection.FindOne(ctx, filter).Decode(&foundBook)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
 			return
 		}
-		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
-		defer cancel()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading book"})
 			return
 		}
-		err = booksCollection.FindOne(ctx, bson.M{"name": book.Name}).Decode(&foundBook)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "book not found"})
+		if cartQuantity(foundUser.Cart, foundBook.ID)+1 > foundBook.Available() {
+			c.JSON(http.StatusConflict, gin.H{"error": "book is out of stock"})
 			return
 		}
 		cart.ID = primitive.NewObjectID()
+		cart.Book_id = foundBook.ID
 		cart.Name = foundBook.Name
 		cart.Price = foundBook.Price
 		cart.Author = foundBook.Author_name
@@ -236,25 +351,7 @@ func UpdateBookQuantity() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-			return
-		}
-		tokenString = tokenString[len("Bearer "):]
-
-		claims, msg := tokens.VerifyToken(tokenString)
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			return
-		}
-
-		var foundUser models.User
-		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
-			return
-		}
+		foundUser := middleware.CurrentUser(c)
 
 		cartID := c.Param("id")
 		if cartID == "" {
@@ -278,8 +375,19 @@ func UpdateBookQuantity() gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "cart item not found"})
 			return
 		}
+		if !updatedItem.Book_id.IsZero() {
+			var foundBook models.Books
+			if err := booksCollection.FindOne(ctx, bson.M{"_id": updatedItem.Book_id}).Decode(&foundBook); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
+				return
+			}
+			if cartQuantity(updatedCart, foundBook.ID) > foundBook.Available() {
+				c.JSON(http.StatusConflict, gin.H{"error": "not enough stock to increase the quantity"})
+				return
+			}
+		}
 
-		_, err = userCollection.UpdateOne(ctx, bson.M{"_id": foundUser.ID}, bson.M{"$set": bson.M{"cart": updatedCart}})
+		_, err := userCollection.UpdateOne(ctx, bson.M{"_id": foundUser.ID}, bson.M{"$set": bson.M{"cart": updatedCart}})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update cart"})
 			return
@@ -295,25 +403,7 @@ func RemoveBookFromCart() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-			return
-		}
-		tokenString = tokenString[len("Bearer "):]
-
-		claims, msg := tokens.VerifyToken(tokenString)
-		if msg != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			return
-		}
-
-		var foundUser models.User
-		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
-			return
-		}
+		foundUser := middleware.CurrentUser(c)
 
 		cartIDToRemove := c.Param("id")
 		if cartIDToRemove == "" {
@@ -336,7 +426,7 @@ func RemoveBookFromCart() gin.HandlerFunc {
 
 		foundUser.Cart = append(foundUser.Cart[:indexToRemove], foundUser.Cart[indexToRemove+1:]...)
 
-		_, err = userCollection.UpdateOne(ctx, bson.M{"_id": foundUser.ID}, bson.M{"$set": bson.M{"cart": foundUser.Cart}})
+		_, err := userCollection.UpdateOne(ctx, bson.M{"_id": foundUser.ID}, bson.M{"$set": bson.M{"cart": foundUser.Cart}})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete item from cart"})
 			return
@@ -347,6 +437,31 @@ func RemoveBookFromCart() gin.HandlerFunc {
 	}
 }
 
+// cartQuantity returns how many copies of the book the cart holds in total.
+func cartQuantity(cart []models.Cart, bookID primitive.ObjectID) int {
+	quantity := 0
+	for _, cartItem := range cart {
+		if cartItem.Book_id == bookID {
+			quantity += cartItem.Quantity
+		}
+	}
+	return quantity
+}
+
+// generateTokens starts a new session for email and returns its access and
+// refresh tokens.
+func generateTokens(ctx context.Context, email string) (token string, refreshToken string, err error) {
+	token, err = tokens.TokenGenerator(email)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err = tokens.IssueRefreshToken(ctx, email)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
 func HashPassword(password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
@@ -0,0 +1,160 @@
+// Package covers validates uploaded book cover images and stores them with
+// thumbnails.
+package covers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxSize is the largest cover accepted, in bytes.
+const MaxSize = 10 << 20
+
+// maxPixels guards against small files that decode to huge images.
+const maxPixels = 50_000_000
+
+const thumbnailQuality = 85
+
+var (
+	ErrTooLarge        = fmt.Errorf("cover must be at most %d MB", MaxSize>>20)
+	ErrUnsupportedType = errors.New("cover must be a JPEG, PNG, GIF or WebP image")
+	ErrInvalidImage    = errors.New("cover is not a valid image")
+)
+
+// extensions maps the accepted content types, as sniffed from the upload,
+// to the extensions originals are stored with.
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// Sizes are the widths of the thumbnails made of every cover. Covers
+// narrower than a size are not scaled up.
+var Sizes = []struct {
+	Name  string
+	Width int
+}{
+	{"small", 150},
+	{"medium", 300},
+	{"large", 600},
+}
+
+// Save checks that data is a supported image, stores it in store with a
+// JPEG thumbnail for each of Sizes, and describes the stored cover. Keys
+// are new for every upload, so URLs can be cached forever.
+func Save(ctx context.Context, store storage.BlobStore, bookID primitive.ObjectID, data []byte) (*models.Cover, error) {
+	if len(data) > MaxSize {
+		return nil, ErrTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	extension, ok := extensions[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width == 0 || config.Height == 0 {
+		return nil, ErrInvalidImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	prefix := "covers/" + bookID.Hex() + "/" + primitive.NewObjectID().Hex() + "/"
+	cover := &models.Cover{
+		Content_type: contentType,
+		Width:        config.Width,
+		Height:       config.Height,
+		Thumbnails:   map[string]string{},
+		Updated_at:   time.Now(),
+	}
+	// Leave nothing behind from a half-stored cover.
+	stored := false
+	defer func() {
+		if !stored {
+			Delete(context.WithoutCancel(ctx), store, cover)
+		}
+	}()
+	put := func(key string, blob []byte, contentType string) error {
+		if err := store.Put(ctx, key, bytes.NewReader(blob), int64(len(blob)), contentType); err != nil {
+			return err
+		}
+		cover.Keys = append(cover.Keys, key)
+		return nil
+	}
+
+	key := prefix + "original." + extension
+	if err := put(key, data, contentType); err != nil {
+		return nil, err
+	}
+	cover.Url = store.URL(key)
+
+	for _, size := range Sizes {
+		thumbnail, err := thumbnail(img, size.Width)
+		if err != nil {
+			return nil, err
+		}
+		key := prefix + size.Name + ".jpg"
+		if err := put(key, thumbnail, "image/jpeg"); err != nil {
+			return nil, err
+		}
+		cover.Thumbnails[size.Name] = store.URL(key)
+	}
+	stored = true
+	return cover, nil
+}
+
+// thumbnail scales img down to width, keeping its aspect ratio, and encodes
+// it as JPEG.
+func thumbnail(img image.Image, width int) ([]byte, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() < width {
+		width = bounds.Dx()
+	}
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	// JPEG has no transparency; show transparent covers on white.
+	draw.Draw(scaled, scaled.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Delete removes every blob of cover from store. It keeps going past
+// failures and returns the first.
+func Delete(ctx context.Context, store storage.BlobStore, cover *models.Cover) error {
+	if cover == nil {
+		return nil
+	}
+	var first error
+	for _, key := range cover.Keys {
+		if err := store.Delete(ctx, key); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
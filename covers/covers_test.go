@@ -0,0 +1,171 @@
+package covers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryStore is a BlobStore in memory whose Put fails once failAfter
+// blobs are stored, if failAfter is positive.
+type memoryStore struct {
+	blobs     map[string]string
+	failAfter int
+}
+
+func (s *memoryStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if s.failAfter > 0 && len(s.blobs) >= s.failAfter {
+		return errors.New("store is full")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.blobs[key] = contentType + ":" + string(data)
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	delete(s.blobs, key)
+	return nil
+}
+
+func (s *memoryStore) URL(key string) string {
+	return "https://cdn.example.com/" + key
+}
+
+// pngImage encodes a width by height PNG.
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.NRGBA{R: 200, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withDimensions rewrites the size in the header of a PNG, keeping the
+// header's checksum valid, without touching the pixel data.
+func withDimensions(data []byte, width, height uint32) []byte {
+	data = bytes.Clone(data)
+	// The signature is 8 bytes, followed by the IHDR chunk's length and
+	// type, its data starting with the width and height, and its CRC.
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestSave(t *testing.T) {
+	ctx := context.Background()
+	bookID := primitive.NewObjectID()
+	store := &memoryStore{blobs: map[string]string{}}
+
+	cover, err := Save(ctx, store, bookID, pngImage(t, 400, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cover.Content_type != "image/png" || cover.Width != 400 || cover.Height != 200 {
+		t.Errorf("cover = %+v, want a 400x200 PNG", cover)
+	}
+	if len(cover.Keys) != 1+len(Sizes) || len(store.blobs) != len(cover.Keys) {
+		t.Fatalf("stored %d blobs under keys %q, want the original and %d thumbnails", len(store.blobs), cover.Keys, len(Sizes))
+	}
+	prefix := "covers/" + bookID.Hex() + "/"
+	for _, key := range cover.Keys {
+		if !strings.HasPrefix(key, prefix) {
+			t.Errorf("key %q is not under %q", key, prefix)
+		}
+	}
+	if !strings.HasSuffix(cover.Url, "/original.png") || !strings.HasPrefix(store.blobs[cover.Keys[0]], "image/png:") {
+		t.Errorf("original stored as %q at %q", store.blobs[cover.Keys[0]][:10], cover.Url)
+	}
+	for _, size := range Sizes {
+		url := cover.Thumbnails[size.Name]
+		key := strings.TrimPrefix(url, "https://cdn.example.com/")
+		blob, ok := store.blobs[key]
+		if !ok || !strings.HasPrefix(blob, "image/jpeg:") {
+			t.Errorf("%s thumbnail at %q was not stored as a JPEG", size.Name, url)
+			continue
+		}
+		config, format, err := image.DecodeConfig(strings.NewReader(strings.TrimPrefix(blob, "image/jpeg:")))
+		if err != nil || format != "jpeg" {
+			t.Errorf("%s thumbnail does not decode: %v", size.Name, err)
+			continue
+		}
+		// Covers are not scaled up past their own width.
+		if want := min(size.Width, 400); config.Width != want || config.Height != want/2 {
+			t.Errorf("%s thumbnail is %dx%d, want %dx%d", size.Name, config.Width, config.Height, want, want/2)
+		}
+	}
+
+	again, err := Save(ctx, store, bookID, pngImage(t, 400, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Keys[0] == cover.Keys[0] {
+		t.Errorf("a second upload reused key %q", again.Keys[0])
+	}
+}
+
+func TestSaveRejects(t *testing.T) {
+	valid := pngImage(t, 10, 10)
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"over the size limit", append(bytes.Clone(valid), make([]byte, MaxSize)...), ErrTooLarge},
+		{"text", []byte("definitely not an image"), ErrUnsupportedType},
+		{"PDF", []byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"), ErrUnsupportedType},
+		// Sniffing goes by content, so an SVG is refused even though it is
+		// an image.
+		{"SVG", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`), ErrUnsupportedType},
+		{"truncated PNG", valid[:20], ErrInvalidImage},
+		{"PNG with no pixels", withDimensions(valid, 0, 10), ErrInvalidImage},
+		{"PNG header promising too many pixels", withDimensions(valid, 10_000, 10_000), ErrTooLarge},
+		{"PNG whose pixels do not match its header", withDimensions(valid, 20, 20), ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryStore{blobs: map[string]string{}}
+			cover, err := Save(context.Background(), store, primitive.NewObjectID(), tt.data)
+			if !errors.Is(err, tt.wantErr) || cover != nil {
+				t.Fatalf("Save = %+v, %v, want %v", cover, err, tt.wantErr)
+			}
+			if len(store.blobs) != 0 {
+				t.Errorf("stored %d blobs for a rejected cover", len(store.blobs))
+			}
+		})
+	}
+}
+
+func TestSaveCleansUpAfterFailedPut(t *testing.T) {
+	for failAfter := 1; failAfter <= len(Sizes); failAfter++ {
+		store := &memoryStore{blobs: map[string]string{}, failAfter: failAfter}
+		if _, err := Save(context.Background(), store, primitive.NewObjectID(), pngImage(t, 400, 200)); err == nil {
+			t.Fatalf("Save succeeded with a store that fails after %d blobs", failAfter)
+		}
+		if len(store.blobs) != 0 {
+			keys := make([]string, 0, len(store.blobs))
+			for key := range store.blobs {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			t.Errorf("failing after %d blobs left %q behind", failAfter, keys)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package database
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"time"
 
@@ -12,7 +11,7 @@ import (
 
 func DBinstance() *mongo.Client {
 	mongoURL := "mongodb://localhost:27017"
-	fmt.Print(mongoURL)
+	log.Print(mongoURL)
 	client, err := mongo.NewClient(options.Client().ApplyURI(mongoURL))
 	if err != nil {
 		log.Fatal(err)
@@ -26,7 +25,7 @@ func DBinstance() *mongo.Client {
 		log.Fatal(err)
 	}
 
-	fmt.Println("Connected to MongoDB server")
+	log.Println("Connected to MongoDB server")
 	return client
 }
 
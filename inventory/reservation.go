@@ -0,0 +1,224 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrReservationNotActive means the order's reservation was already
+// committed, released or never existed. The first two cases are told apart
+// by ErrReservationCommitted and ErrReservationReleased, which both match it.
+var ErrReservationNotActive = errors.New("stock reservation is not active")
+
+var (
+	ErrReservationCommitted = fmt.Errorf("%w: it was committed", ErrReservationNotActive)
+	ErrReservationReleased  = fmt.Errorf("%w: it was released", ErrReservationNotActive)
+)
+
+var reservationsCollection *mongo.Collection = database.OpenCollection(database.Client, "reservations")
+
+// Reserve holds lines for the order until ttl passes. Reserved copies stay
+// in stock but cannot be reserved or bought by anyone else. Either every
+// line is reserved or none is.
+func Reserve(ctx context.Context, orderID primitive.ObjectID, lines []Line, ttl time.Duration) (models.Reservation, error) {
+	for i, line := range lines {
+		result, err := booksCollection.UpdateOne(ctx,
+			bson.M{"_id": line.Book_id, "$expr": availableAtLeast(line.Quantity)},
+			bson.M{"$inc": bson.M{"reserved": line.Quantity}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			err = &InsufficientStockError{Book_id: line.Book_id, Name: line.Name}
+		}
+		if err != nil {
+			return models.Reservation{}, rollback(err, func(ctx context.Context) error {
+				return unreserve(ctx, reservedLines(lines[:i]))
+			})
+		}
+	}
+
+	now := time.Now()
+	reservation := models.Reservation{
+		ID:         primitive.NewObjectID(),
+		Order_id:   orderID,
+		Lines:      reservedLines(lines),
+		Status:     models.ReservationActive,
+		Expires_at: now.Add(ttl),
+		Created_at: now,
+		Updated_at: now,
+	}
+	if _, err := reservationsCollection.InsertOne(ctx, reservation); err != nil {
+		return models.Reservation{}, rollback(err, func(ctx context.Context) error {
+			return unreserve(ctx, reservation.Lines)
+		})
+	}
+	return reservation, nil
+}
+
+// Commit turns the order's reservation into sold stock.
+func Commit(ctx context.Context, orderID primitive.ObjectID) error {
+	reservation, err := finish(ctx, orderID, models.ReservationActive, models.ReservationCommitted)
+	if err != nil {
+		return err
+	}
+	for _, line := range reservation.Lines {
+		_, err := booksCollection.UpdateOne(ctx,
+			bson.M{"_id": line.Book_id},
+			bson.M{"$inc": bson.M{"stock": -line.Quantity, "reserved": -line.Quantity, "sold_count": line.Quantity}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release gives the order's reserved copies back to everyone else.
+func Release(ctx context.Context, orderID primitive.ObjectID) error {
+	reservation, err := finish(ctx, orderID, models.ReservationActive, models.ReservationReleased)
+	if err != nil {
+		return err
+	}
+	return unreserve(ctx, reservation.Lines)
+}
+
+// Uncommit undoes Commit for a sale that fell through. The copies go back
+// into stock and the reservation counts as released, so selling them again
+// has to take them from the shelf like any other purchase.
+func Uncommit(ctx context.Context, orderID primitive.ObjectID) error {
+	reservation, err := finish(ctx, orderID, models.ReservationCommitted, models.ReservationReleased)
+	if err != nil {
+		return err
+	}
+	for _, line := range reservation.Lines {
+		_, err := booksCollection.UpdateOne(ctx,
+			bson.M{"_id": line.Book_id},
+			bson.M{"$inc": bson.M{"stock": line.Quantity, "sold_count": -line.Quantity}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartSweeper releases expired reservations every interval and calls
+// onExpired for each of their orders. It runs until the process exits.
+func StartSweeper(interval time.Duration, onExpired func(ctx context.Context, orderID primitive.ObjectID)) {
+	go func() {
+		for range time.Tick(interval) {
+			if err := sweep(onExpired); err != nil {
+				log.Println("failed to sweep expired reservations:", err)
+			}
+		}
+	}()
+}
+
+func sweep(onExpired func(ctx context.Context, orderID primitive.ObjectID)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := reservationsCollection.Find(ctx, bson.M{
+		"status":     models.ReservationActive,
+		"expires_at": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	var expired []models.Reservation
+	if err := cursor.All(ctx, &expired); err != nil {
+		return err
+	}
+	for _, reservation := range expired {
+		err := Release(ctx, reservation.Order_id)
+		if errors.Is(err, ErrReservationNotActive) {
+			// Paid or released concurrently.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		onExpired(ctx, reservation.Order_id)
+	}
+	return nil
+}
+
+// finish moves the order's reservation from status from to status to and
+// returns it. Only one caller can finish a reservation, which makes commit
+// and release mutually exclusive.
+func finish(ctx context.Context, orderID primitive.ObjectID, from, to string) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := reservationsCollection.FindOneAndUpdate(ctx,
+		bson.M{"order_id": orderID, "status": from},
+		bson.M{"$set": bson.M{"status": to, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&reservation)
+	if err != mongo.ErrNoDocuments {
+		return reservation, err
+	}
+
+	err = reservationsCollection.FindOne(ctx, bson.M{"order_id": orderID}).Decode(&reservation)
+	if err == mongo.ErrNoDocuments {
+		return models.Reservation{}, ErrReservationNotActive
+	}
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	switch reservation.Status {
+	case models.ReservationCommitted:
+		return models.Reservation{}, ErrReservationCommitted
+	case models.ReservationReleased:
+		return models.Reservation{}, ErrReservationReleased
+	}
+	return models.Reservation{}, ErrReservationNotActive
+}
+
+func unreserve(ctx context.Context, lines []models.ReservedLine) error {
+	for _, line := range lines {
+		_, err := booksCollection.UpdateOne(ctx,
+			bson.M{"_id": line.Book_id},
+			bson.M{"$inc": bson.M{"reserved": -line.Quantity}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reservedLines(lines []Line) []models.ReservedLine {
+	reserved := make([]models.ReservedLine, 0, len(lines))
+	for _, line := range lines {
+		reserved = append(reserved, models.ReservedLine{Book_id: line.Book_id, Quantity: line.Quantity})
+	}
+	return reserved
+}
+
+// rollback runs undo with a fresh context, since the caller's may be what
+// just expired, and returns err together with any error of undo.
+func rollback(err error, undo func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if undoErr := undo(ctx); undoErr != nil {
+		return errors.Join(err, undoErr)
+	}
+	return err
+}
+
+// EnsureIndexes creates the indexes reservations are looked up by.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := reservationsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "order_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+	})
+	return err
+}
@@ -0,0 +1,152 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func reservationDoc(orderID, bookID primitive.ObjectID, quantity int, status string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "order_id", Value: orderID},
+		{Key: "lines", Value: bson.A{bson.D{{Key: "book_id", Value: bookID}, {Key: "quantity", Value: quantity}}}},
+		{Key: "status", Value: status},
+	}
+}
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	orderID, dune, emma := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	lines := []Line{{Book_id: dune, Name: "Dune", Quantity: 2}, {Book_id: emma, Name: "Emma", Quantity: 1}}
+
+	tests := []struct {
+		name        string
+		responses   []bson.D
+		wantErr     bool
+		wantUpdates []update
+	}{
+		{
+			name:      "all available",
+			responses: []bson.D{updated(1), updated(1), updated(1)},
+			wantUpdates: []update{
+				inc(dune, map[string]int{"reserved": 2}),
+				inc(emma, map[string]int{"reserved": 1}),
+			},
+		},
+		{
+			name:      "second book unavailable gives the first back",
+			responses: []bson.D{updated(1), updated(0), updated(1)},
+			wantErr:   true,
+			wantUpdates: []update{
+				inc(dune, map[string]int{"reserved": 2}),
+				inc(emma, map[string]int{"reserved": 1}),
+				inc(dune, map[string]int{"reserved": -2}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			booksCollection, reservationsCollection = mt.Coll, mt.Coll
+			mt.AddMockResponses(tt.responses...)
+			reservation, err := Reserve(ctx, orderID, lines, time.Minute)
+			var insufficient *InsufficientStockError
+			if tt.wantErr != errors.As(err, &insufficient) {
+				mt.Fatalf("Reserve error = %v, want insufficient stock: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (reservation.Order_id != orderID || reservation.Status != models.ReservationActive || len(reservation.Lines) != 2) {
+				mt.Errorf("Reserve = %+v", reservation)
+			}
+			if got := updates(mt); !reflect.DeepEqual(got, tt.wantUpdates) {
+				mt.Errorf("updates = %+v, want %+v", got, tt.wantUpdates)
+			}
+		})
+	}
+}
+
+func TestCommitAndUncommit(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	orderID, bookID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	tests := []struct {
+		name        string
+		finish      func(context.Context, primitive.ObjectID) error
+		status      string
+		wantUpdates []update
+	}{
+		{
+			name:        "commit sells the reserved copies",
+			finish:      Commit,
+			status:      models.ReservationCommitted,
+			wantUpdates: []update{inc(bookID, map[string]int{"stock": -3, "reserved": -3, "sold_count": 3})},
+		},
+		{
+			name:        "uncommit puts them back",
+			finish:      Uncommit,
+			status:      models.ReservationReleased,
+			wantUpdates: []update{inc(bookID, map[string]int{"stock": 3, "sold_count": -3})},
+		},
+		{
+			name:        "release only unreserves",
+			finish:      Release,
+			status:      models.ReservationReleased,
+			wantUpdates: []update{inc(bookID, map[string]int{"reserved": -3})},
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			booksCollection, reservationsCollection = mt.Coll, mt.Coll
+			mt.AddMockResponses(
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: reservationDoc(orderID, bookID, 3, tt.status)}),
+				updated(1),
+			)
+			if err := tt.finish(ctx, orderID); err != nil {
+				mt.Fatal(err)
+			}
+			if got := updates(mt); !reflect.DeepEqual(got, tt.wantUpdates) {
+				mt.Errorf("updates = %+v, want %+v", got, tt.wantUpdates)
+			}
+		})
+	}
+}
+
+func TestFinishReportsWhyTheReservationIsNotActive(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	orderID, bookID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	tests := []struct {
+		name    string
+		found   []bson.D
+		wantErr error
+	}{
+		{"already committed", []bson.D{reservationDoc(orderID, bookID, 1, models.ReservationCommitted)}, ErrReservationCommitted},
+		{"already released", []bson.D{reservationDoc(orderID, bookID, 1, models.ReservationReleased)}, ErrReservationReleased},
+		{"never reserved", nil, ErrReservationNotActive},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			booksCollection, reservationsCollection = mt.Coll, mt.Coll
+			mt.AddMockResponses(
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+				mtest.CreateCursorResponse(0, "test.reservations", mtest.FirstBatch, tt.found...),
+			)
+			err := Commit(ctx, orderID)
+			if err != tt.wantErr || !errors.Is(err, ErrReservationNotActive) {
+				mt.Fatalf("Commit error = %v, want %v", err, tt.wantErr)
+			}
+			if got := updates(mt); len(got) != 0 {
+				mt.Errorf("Commit changed stock: %+v", got)
+			}
+		})
+	}
+}
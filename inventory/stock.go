@@ -0,0 +1,169 @@
+// Package inventory keeps the stock levels on books consistent while
+// customers buy them concurrently.
+package inventory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrBookNotFound = errors.New("book not found")
+	// ErrNegativeStock is returned by Adjust when the change would leave
+	// fewer copies than are reserved.
+	ErrNegativeStock = errors.New("stock cannot drop below the reserved quantity")
+)
+
+// InsufficientStockError names the book that could not be supplied.
+type InsufficientStockError struct {
+	Book_id primitive.ObjectID
+	Name    string
+}
+
+func (e *InsufficientStockError) Error() string {
+	return "not enough stock for " + e.Name
+}
+
+// Line is a quantity of one book.
+type Line struct {
+	Book_id  primitive.ObjectID
+	Name     string
+	Quantity int
+}
+
+var booksCollection *mongo.Collection = database.OpenCollection(database.Client, "books")
+var adjustmentsCollection *mongo.Collection = database.OpenCollection(database.Client, "stock_adjustments")
+
+// LinesFor merges order items of the same book into one line each.
+func LinesFor(items []models.OrderItem) []Line {
+	var lines []Line
+	index := map[primitive.ObjectID]int{}
+	for _, item := range items {
+		if i, ok := index[item.Book_id]; ok {
+			lines[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.Book_id] = len(lines)
+		lines = append(lines, Line{Book_id: item.Book_id, Name: item.Name, Quantity: item.Quantity})
+	}
+	return lines
+}
+
+// Decrement takes every line out of stock, or none of them. Each book is
+// only decremented if enough unreserved copies are left at that moment, so
+// concurrent buyers can never drive stock below what is held for others.
+func Decrement(ctx context.Context, lines []Line) error {
+	for i, line := range lines {
+		result, err := booksCollection.UpdateOne(ctx,
+			bson.M{"_id": line.Book_id, "$expr": availableAtLeast(line.Quantity)},
+			bson.M{"$inc": bson.M{"stock": -line.Quantity, "sold_count": line.Quantity}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			err = &InsufficientStockError{Book_id: line.Book_id, Name: line.Name}
+		}
+		if err != nil {
+			return rollback(err, func(ctx context.Context) error {
+				return Restock(ctx, lines[:i])
+			})
+		}
+	}
+	return nil
+}
+
+// Restock undoes a sale, putting lines back into stock.
+func Restock(ctx context.Context, lines []Line) error {
+	for _, line := range lines {
+		_, err := booksCollection.UpdateOne(ctx,
+			bson.M{"_id": line.Book_id},
+			bson.M{"$inc": bson.M{"stock": line.Quantity, "sold_count": -line.Quantity}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adjust changes a book's stock by delta on behalf of by and records why.
+// It returns the new stock level.
+func Adjust(ctx context.Context, bookID primitive.ObjectID, delta int, reason, by string) (int, error) {
+	filter := bson.M{"_id": bookID}
+	if delta < 0 {
+		// Copies held for a checkout cannot be taken off the shelf.
+		filter["$expr"] = availableAtLeast(-delta)
+	}
+	var book models.Books
+	err := booksCollection.FindOneAndUpdate(ctx,
+		filter,
+		bson.M{"$inc": bson.M{"stock": delta}, "$set": bson.M{"updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&book)
+	if err == mongo.ErrNoDocuments {
+		count, countErr := booksCollection.CountDocuments(ctx, bson.M{"_id": bookID})
+		if countErr != nil {
+			return 0, countErr
+		}
+		if count == 0 {
+			return 0, ErrBookNotFound
+		}
+		return 0, ErrNegativeStock
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = adjustmentsCollection.InsertOne(ctx, models.StockAdjustment{
+		ID:          primitive.NewObjectID(),
+		Book_id:     bookID,
+		Delta:       delta,
+		Reason:      reason,
+		By:          by,
+		Stock_after: book.Stock,
+		Created_at:  time.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return book.Stock, nil
+}
+
+// MigrateStock gives books stored before stock was tracked a stock level of
+// initial and returns them. Until then they have no stock field, which
+// counts as nothing available.
+func MigrateStock(ctx context.Context, initial int) ([]models.Books, error) {
+	legacy := bson.M{"stock": bson.M{"$exists": false}}
+	cursor, err := booksCollection.Find(ctx, legacy, options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var books []models.Books
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	if len(books) == 0 {
+		return nil, nil
+	}
+	_, err = booksCollection.UpdateMany(ctx, legacy, bson.M{"$set": bson.M{"stock": initial, "updated_at": time.Now()}})
+	if err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// availableAtLeast matches books with at least quantity copies that are
+// neither sold nor reserved. Books stored before reservations existed have
+// no reserved field, which counts as zero.
+func availableAtLeast(quantity int) bson.M {
+	return bson.M{"$gte": bson.A{
+		bson.M{"$subtract": bson.A{"$stock", bson.M{"$ifNull": bson.A{"$reserved", 0}}}},
+		quantity,
+	}}
+}
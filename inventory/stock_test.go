@@ -0,0 +1,235 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// updated answers an update command as if it matched n documents.
+func updated(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+// update is the part of an update command the tests look at.
+type update struct {
+	Q struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	U struct {
+		Inc map[string]int `bson:"$inc"`
+	}
+}
+
+// updates returns every update statement mt sent, in order.
+func updates(mt *mtest.T) []update {
+	var all []update
+	for _, event := range mt.GetAllStartedEvents() {
+		if event.CommandName != "update" {
+			continue
+		}
+		var command struct{ Updates []update }
+		if err := bson.Unmarshal(event.Command, &command); err != nil {
+			mt.Fatal(err)
+		}
+		all = append(all, command.Updates...)
+	}
+	return all
+}
+
+// inc is an update that changes the counters of bookID by fields.
+func inc(bookID primitive.ObjectID, fields map[string]int) update {
+	var u update
+	u.Q.ID = bookID
+	u.U.Inc = fields
+	return u
+}
+
+func TestLinesFor(t *testing.T) {
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	tests := []struct {
+		name  string
+		items []models.OrderItem
+		want  []Line
+	}{
+		{"no items", nil, nil},
+		{
+			"one line per book, in order",
+			[]models.OrderItem{{Book_id: a, Name: "Dune", Quantity: 1}, {Book_id: b, Name: "Emma", Quantity: 2}, {Book_id: a, Name: "Dune", Quantity: 3}},
+			[]Line{{Book_id: a, Name: "Dune", Quantity: 4}, {Book_id: b, Name: "Emma", Quantity: 2}},
+		},
+	}
+	for _, tt := range tests {
+		if got := LinesFor(tt.items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: LinesFor = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecrement(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	dune, emma := primitive.NewObjectID(), primitive.NewObjectID()
+	lines := []Line{{Book_id: dune, Name: "Dune", Quantity: 2}, {Book_id: emma, Name: "Emma", Quantity: 1}}
+
+	tests := []struct {
+		name        string
+		matched     []int
+		wantErr     bool
+		wantUpdates []update
+	}{
+		{
+			name:    "all in stock",
+			matched: []int{1, 1},
+			wantUpdates: []update{
+				inc(dune, map[string]int{"stock": -2, "sold_count": 2}),
+				inc(emma, map[string]int{"stock": -1, "sold_count": 1}),
+			},
+		},
+		{
+			name:    "second book sold out puts the first back",
+			matched: []int{1, 0, 1},
+			wantErr: true,
+			wantUpdates: []update{
+				inc(dune, map[string]int{"stock": -2, "sold_count": 2}),
+				inc(emma, map[string]int{"stock": -1, "sold_count": 1}),
+				inc(dune, map[string]int{"stock": 2, "sold_count": -2}),
+			},
+		},
+		{
+			name:    "first book sold out",
+			matched: []int{0},
+			wantErr: true,
+			wantUpdates: []update{
+				inc(dune, map[string]int{"stock": -2, "sold_count": 2}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			booksCollection = mt.Coll
+			for _, n := range tt.matched {
+				mt.AddMockResponses(updated(n))
+			}
+			err := Decrement(ctx, lines)
+			var insufficient *InsufficientStockError
+			if tt.wantErr != errors.As(err, &insufficient) {
+				mt.Fatalf("Decrement error = %v, want insufficient stock: %v", err, tt.wantErr)
+			}
+			if got := updates(mt); !reflect.DeepEqual(got, tt.wantUpdates) {
+				mt.Errorf("updates = %+v, want %+v", got, tt.wantUpdates)
+			}
+		})
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	bookID := primitive.NewObjectID()
+	book := bson.D{{Key: "_id", Value: bookID}, {Key: "stock", Value: 7}}
+
+	tests := []struct {
+		name      string
+		delta     int
+		responses []bson.D
+		want      int
+		wantErr   error
+	}{
+		{
+			name:  "restock",
+			delta: 5,
+			responses: []bson.D{
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: book}),
+				updated(1),
+			},
+			want: 7,
+		},
+		{
+			name:  "more than is unreserved",
+			delta: -3,
+			responses: []bson.D{
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+				mtest.CreateCursorResponse(0, "test.books", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+			},
+			wantErr: ErrNegativeStock,
+		},
+		{
+			name:  "unknown book",
+			delta: -3,
+			responses: []bson.D{
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+				mtest.CreateCursorResponse(0, "test.books", mtest.FirstBatch, bson.D{{Key: "n", Value: 0}}),
+			},
+			wantErr: ErrBookNotFound,
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			booksCollection, adjustmentsCollection = mt.Coll, mt.Coll
+			mt.AddMockResponses(tt.responses...)
+			got, err := Adjust(ctx, bookID, tt.delta, "stocktake", "admin@example.com")
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				mt.Fatalf("Adjust = %d, %v, want %d, %v", got, err, tt.want, tt.wantErr)
+			}
+
+			// Only taking copies away is limited by what is reserved.
+			var command struct {
+				Query bson.Raw
+			}
+			if err := bson.Unmarshal(mt.GetAllStartedEvents()[0].Command, &command); err != nil {
+				mt.Fatal(err)
+			}
+			_, err = command.Query.LookupErr("$expr")
+			if limited := err == nil; limited != (tt.delta < 0) {
+				mt.Errorf("filter %s limits by availability: %v, want %v", command.Query, limited, tt.delta < 0)
+			}
+		})
+	}
+}
+
+func TestMigrateStock(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("legacy books", func(mt *mtest.T) {
+		booksCollection = mt.Coll
+		legacy := bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "name", Value: "Dune"}}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.books", mtest.FirstBatch, legacy), updated(1))
+		books, err := MigrateStock(ctx, 3)
+		if err != nil || len(books) != 1 || books[0].Name != "Dune" {
+			mt.Fatalf("MigrateStock = %+v, %v", books, err)
+		}
+		var command struct {
+			Updates []struct {
+				Q bson.M
+				U struct {
+					Set struct{ Stock int } `bson:"$set"`
+				}
+			}
+		}
+		events := mt.GetAllStartedEvents()
+		if err := bson.Unmarshal(events[len(events)-1].Command, &command); err != nil {
+			mt.Fatal(err)
+		}
+		if len(command.Updates) != 1 || command.Updates[0].U.Set.Stock != 3 || command.Updates[0].Q["stock"] == nil {
+			mt.Errorf("update = %+v, want stock set to 3 where it is missing", command.Updates)
+		}
+	})
+	mt.Run("nothing to migrate", func(mt *mtest.T) {
+		booksCollection = mt.Coll
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.books", mtest.FirstBatch))
+		if books, err := MigrateStock(ctx, 3); err != nil || len(books) != 0 {
+			mt.Fatalf("MigrateStock = %+v, %v", books, err)
+		}
+		if n := len(mt.GetAllStartedEvents()); n != 1 {
+			mt.Errorf("sent %d commands, want only the find", n)
+		}
+	})
+}
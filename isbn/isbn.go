@@ -0,0 +1,161 @@
+// Package isbn validates International Standard Book Numbers and converts
+// between their 10 and 13 digit forms.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalid       = errors.New("invalid ISBN")
+	ErrNoISBN10      = errors.New("ISBN-13 has no ISBN-10 form")
+	ErrISBNsDisagree = errors.New("ISBN-10 and ISBN-13 name different books")
+)
+
+// bookland is the prefix of the ISBN-13s converted from ISBN-10s.
+const bookland = "978"
+
+// Normalize strips the hyphens and spaces ISBNs are usually printed with
+// and upper-cases a trailing x.
+func Normalize(s string) string {
+	s = strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s))
+	return strings.ToUpper(s)
+}
+
+// Valid10 reports whether s, once normalized, is an ISBN-10 with a correct
+// check digit.
+func Valid10(s string) bool {
+	s = Normalize(s)
+	if len(s) != 10 || !digits(s[:9]) {
+		return false
+	}
+	return check10(s[:9]) == s[9]
+}
+
+// Valid13 reports whether s, once normalized, is an ISBN-13 with a correct
+// check digit. Other EAN-13 barcodes, such as those of non-book products,
+// are rejected by their prefix.
+func Valid13(s string) bool {
+	s = Normalize(s)
+	if len(s) != 13 || !digits(s) {
+		return false
+	}
+	if !strings.HasPrefix(s, bookland) && !strings.HasPrefix(s, "979") {
+		return false
+	}
+	return check13(s[:12]) == s[12]
+}
+
+// To13 converts an ISBN-10 to its ISBN-13.
+func To13(s string) (string, error) {
+	if !Valid10(s) {
+		return "", ErrInvalid
+	}
+	body := bookland + Normalize(s)[:9]
+	return body + string(check13(body)), nil
+}
+
+// To10 converts an ISBN-13 to its ISBN-10. Only ISBN-13s starting with 978
+// have one.
+func To10(s string) (string, error) {
+	if !Valid13(s) {
+		return "", ErrInvalid
+	}
+	s = Normalize(s)
+	if !strings.HasPrefix(s, bookland) {
+		return "", ErrNoISBN10
+	}
+	body := s[3:12]
+	return body + string(check10(body)), nil
+}
+
+// Parse accepts either form and returns both, normalized. isbn10 is empty
+// for ISBN-13s without an ISBN-10 form.
+func Parse(s string) (isbn10, isbn13 string, err error) {
+	s = Normalize(s)
+	switch len(s) {
+	case 10:
+		isbn13, err = To13(s)
+		if err != nil {
+			return "", "", err
+		}
+		return s, isbn13, nil
+	case 13:
+		if !Valid13(s) {
+			return "", "", ErrInvalid
+		}
+		isbn10, err = To10(s)
+		if err == ErrNoISBN10 {
+			err = nil
+		}
+		return isbn10, s, err
+	}
+	return "", "", ErrInvalid
+}
+
+// Reconcile validates a book's ISBN-10 and ISBN-13, either of which may be
+// empty, and returns both forms. It fails if both are given but differ.
+func Reconcile(isbn10, isbn13 string) (string, string, error) {
+	if isbn10 == "" && isbn13 == "" {
+		return "", "", nil
+	}
+	if isbn13 == "" {
+		if !Valid10(isbn10) {
+			return "", "", ErrInvalid
+		}
+		return Parse(isbn10)
+	}
+	if !Valid13(isbn13) {
+		return "", "", ErrInvalid
+	}
+	from13, normalized13, err := Parse(isbn13)
+	if err != nil {
+		return "", "", err
+	}
+	if isbn10 != "" {
+		if !Valid10(isbn10) {
+			return "", "", ErrInvalid
+		}
+		if Normalize(isbn10) != from13 {
+			return "", "", ErrISBNsDisagree
+		}
+	}
+	return from13, normalized13, nil
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// check10 computes the check digit for the first nine digits of an ISBN-10.
+func check10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// check13 computes the check digit for the first twelve digits of an
+// ISBN-13.
+func check13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(body[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
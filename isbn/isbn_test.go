@@ -0,0 +1,127 @@
+package isbn
+
+import "testing"
+
+func TestValid10(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want bool
+	}{
+		{"0306406152", true},
+		{"0-306-40615-2", true},
+		{"080442957X", true},
+		{"080442957x", true},
+		{"0306406153", false},
+		{"030640615", false},
+		{"03064061522", false},
+		{"03064O6152", false},
+		{"X306406152", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Valid10(tt.isbn); got != tt.want {
+			t.Errorf("Valid10(%q) = %v, want %v", tt.isbn, got, tt.want)
+		}
+	}
+}
+
+func TestValid13(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want bool
+	}{
+		{"9780306406157", true},
+		{"978-0-306-40615-7", true},
+		{"9791034304059", true},
+		{"9780306406158", false},
+		{"978030640615", false},
+		{"978030640615X", false},
+		{"4006381333931", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Valid13(tt.isbn); got != tt.want {
+			t.Errorf("Valid13(%q) = %v, want %v", tt.isbn, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		isbn    string
+		want10  string
+		want13  string
+		wantErr error
+	}{
+		{isbn: "0306406152", want10: "0306406152", want13: "9780306406157"},
+		{isbn: "080442957x", want10: "080442957X", want13: "9780804429573"},
+		{isbn: "978-0-306-40615-7", want10: "0306406152", want13: "9780306406157"},
+		{isbn: "9791034304059", want13: "9791034304059"},
+		{isbn: "0306406153", wantErr: ErrInvalid},
+		{isbn: "9780306406158", wantErr: ErrInvalid},
+		{isbn: "4006381333931", wantErr: ErrInvalid},
+		{isbn: "12345", wantErr: ErrInvalid},
+	}
+	for _, tt := range tests {
+		got10, got13, err := Parse(tt.isbn)
+		if err != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.isbn, err, tt.wantErr)
+			continue
+		}
+		if got10 != tt.want10 || got13 != tt.want13 {
+			t.Errorf("Parse(%q) = %q, %q, want %q, %q", tt.isbn, got10, got13, tt.want10, tt.want13)
+		}
+	}
+}
+
+func TestTo10(t *testing.T) {
+	tests := []struct {
+		isbn    string
+		want    string
+		wantErr error
+	}{
+		{"9780306406157", "0306406152", nil},
+		{"9780804429573", "080442957X", nil},
+		{"9791034304059", "", ErrNoISBN10},
+		{"9780306406158", "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		got, err := To10(tt.isbn)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("To10(%q) = %q, %v, want %q, %v", tt.isbn, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name    string
+		isbn10  string
+		isbn13  string
+		want10  string
+		want13  string
+		wantErr error
+	}{
+		{name: "neither", want10: "", want13: ""},
+		{name: "only ISBN-10", isbn10: "0-306-40615-2", want10: "0306406152", want13: "9780306406157"},
+		{name: "only ISBN-13", isbn13: "978-0-306-40615-7", want10: "0306406152", want13: "9780306406157"},
+		{name: "both agree", isbn10: "0306406152", isbn13: "9780306406157", want10: "0306406152", want13: "9780306406157"},
+		{name: "ISBN-13 without ISBN-10 form", isbn13: "9791034304059", want13: "9791034304059"},
+		{name: "both disagree", isbn10: "080442957X", isbn13: "9780306406157", wantErr: ErrISBNsDisagree},
+		{name: "ISBN-10 given for 979 prefix", isbn10: "0306406152", isbn13: "9791034304059", wantErr: ErrISBNsDisagree},
+		{name: "bad ISBN-10", isbn10: "0306406153", wantErr: ErrInvalid},
+		{name: "bad ISBN-13", isbn13: "9780306406158", wantErr: ErrInvalid},
+		{name: "bad ISBN-10 next to good ISBN-13", isbn10: "0306406153", isbn13: "9780306406157", wantErr: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got10, got13, err := Reconcile(tt.isbn10, tt.isbn13)
+			if err != tt.wantErr {
+				t.Fatalf("Reconcile(%q, %q) error = %v, want %v", tt.isbn10, tt.isbn13, err, tt.wantErr)
+			}
+			if got10 != tt.want10 || got13 != tt.want13 {
+				t.Errorf("Reconcile(%q, %q) = %q, %q, want %q, %q", tt.isbn10, tt.isbn13, got10, got13, tt.want10, tt.want13)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Package lockout slows down and eventually blocks repeated failed logins.
+// Failures are counted per key, where a key is an account or a client IP.
+package lockout
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// recordTTL is how long a key has to stay quiet before its failures are
+// forgotten.
+const recordTTL = 24 * time.Hour
+
+// Record is the failure history of a single key.
+type Record struct {
+	Key           string
+	Failures      int
+	Blocked_until time.Time
+	Updated_at    time.Time
+}
+
+// Store keeps failure records. Implementations must make Increment atomic,
+// since concurrent attempts against the same key are exactly what this
+// package defends against.
+type Store interface {
+	// Get returns the record for key, or a zero Record if there is none.
+	Get(ctx context.Context, key string) (Record, error)
+	// Increment adds one failure to key and returns the new count.
+	Increment(ctx context.Context, key string) (int, error)
+	Block(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy describes how quickly a key is slowed down. The first FreeAttempts
+// failures cost nothing; after that every failure doubles the wait, starting
+// at BaseDelay and capped at MaxDelay. Reaching LockoutThreshold failures
+// blocks the key for LockoutDuration.
+type Policy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+}
+
+var (
+	AccountPolicy = Policy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  30 * time.Minute,
+	}
+	// IPPolicy is looser because several users can share an address.
+	IPPolicy = Policy{
+		FreeAttempts:     10,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 100,
+		LockoutDuration:  time.Hour,
+	}
+)
+
+// blockFor returns how long a key with failures failures must wait.
+func (p Policy) blockFor(failures int) time.Duration {
+	if failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+type Guard struct {
+	store   Store
+	prefix  string
+	account Policy
+	ip      Policy
+}
+
+// NewGuard throttles failed logins under AccountPolicy and IPPolicy.
+func NewGuard(store Store) *Guard {
+	return &Guard{store: store, account: AccountPolicy, ip: IPPolicy}
+}
+
+// NewThrottle limits some other action per account and per IP, with every
+// attempt recorded through Failure. Its records are kept under prefix, so it
+// can share a store with the login guard without the counts mixing.
+func NewThrottle(store Store, prefix string, account, ip Policy) *Guard {
+	return &Guard{store: store, prefix: prefix, account: account, ip: ip}
+}
+
+// Check returns how long the caller has to wait before another login
+// attempt for email from ip is allowed. Zero means go ahead.
+func (g *Guard) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range []string{g.accountKey(email), g.ipKey(ip)} {
+		record, err := g.store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, time.Until(record.Blocked_until))
+	}
+	return wait, nil
+}
+
+// Failure records a failed attempt for email from ip. It counts for unknown
+// emails too, so lockouts do not reveal which accounts exist.
+func (g *Guard) Failure(ctx context.Context, email, ip string) error {
+	if err := g.fail(ctx, g.accountKey(email), g.account); err != nil {
+		return err
+	}
+	return g.fail(ctx, g.ipKey(ip), g.ip)
+}
+
+// Success clears the account's failures. The IP's failures are kept so an
+// attacker cannot reset them by logging into an account of their own.
+func (g *Guard) Success(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, g.accountKey(email))
+}
+
+// Unlock lifts a lockout of the account early.
+func (g *Guard) Unlock(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, g.accountKey(email))
+}
+
+func (g *Guard) fail(ctx context.Context, key string, policy Policy) error {
+	failures, err := g.store.Increment(ctx, key)
+	if err != nil {
+		return err
+	}
+	if wait := policy.blockFor(failures); wait > 0 {
+		return g.store.Block(ctx, key, time.Now().Add(wait))
+	}
+	return nil
+}
+
+func (g *Guard) accountKey(email string) string {
+	return g.prefix + "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func (g *Guard) ipKey(ip string) string {
+	return g.prefix + "ip:" + ip
+}
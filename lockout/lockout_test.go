@@ -0,0 +1,203 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBlockFor(t *testing.T) {
+	capped := Policy{
+		FreeAttempts:     0,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Second,
+		LockoutThreshold: 100,
+		LockoutDuration:  time.Hour,
+	}
+	tests := []struct {
+		name     string
+		policy   Policy
+		failures int
+		want     time.Duration
+	}{
+		{"no failures", AccountPolicy, 0, 0},
+		{"last free attempt", AccountPolicy, 3, 0},
+		{"first delayed attempt", AccountPolicy, 4, time.Second},
+		{"delay doubles", AccountPolicy, 5, 2 * time.Second},
+		{"delay keeps doubling", AccountPolicy, 9, 32 * time.Second},
+		{"lockout threshold", AccountPolicy, 10, 30 * time.Minute},
+		{"past lockout threshold", AccountPolicy, 25, 30 * time.Minute},
+		{"ip free attempts", IPPolicy, 10, 0},
+		{"ip first delay", IPPolicy, 11, time.Second},
+		{"ip lockout", IPPolicy, 100, time.Hour},
+		{"without free attempts", capped, 1, time.Second},
+		{"below the cap", capped, 3, 4 * time.Second},
+		{"at the cap", capped, 4, 5 * time.Second},
+		{"far past the cap", capped, 99, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.blockFor(tt.failures); got != tt.want {
+				t.Errorf("blockFor(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	const ip = "192.0.2.1"
+
+	tests := []struct {
+		name string
+		// run drives a fresh guard before it is checked for email and ip.
+		run      func(g *Guard) error
+		email    string
+		ip       string
+		wantWait bool
+	}{
+		{
+			name:  "free attempts",
+			run:   failures("alice@example.com", ip, AccountPolicy.FreeAttempts),
+			email: "alice@example.com", ip: ip,
+		},
+		{
+			name:  "account slowed down",
+			run:   failures("alice@example.com", ip, AccountPolicy.FreeAttempts+1),
+			email: "alice@example.com", ip: ip, wantWait: true,
+		},
+		{
+			name:  "account keys ignore case and spaces",
+			run:   failures(" Alice@Example.com", ip, AccountPolicy.FreeAttempts+1),
+			email: "alice@example.com", ip: "198.51.100.7", wantWait: true,
+		},
+		{
+			name:  "other accounts on another address are unaffected",
+			run:   failures("alice@example.com", ip, AccountPolicy.FreeAttempts+1),
+			email: "bob@example.com", ip: "198.51.100.7",
+		},
+		{
+			name:  "address slowed down across accounts",
+			run:   spread(ip, IPPolicy.FreeAttempts+1),
+			email: "someone-new@example.com", ip: ip, wantWait: true,
+		},
+		{
+			name: "success clears the account",
+			run: func(g *Guard) error {
+				if err := failures("alice@example.com", "198.51.100.7", AccountPolicy.FreeAttempts+1)(g); err != nil {
+					return err
+				}
+				return g.Success(ctx, "alice@example.com")
+			},
+			email: "alice@example.com", ip: ip,
+		},
+		{
+			name: "success keeps the address failures",
+			run: func(g *Guard) error {
+				if err := spread(ip, IPPolicy.FreeAttempts+1)(g); err != nil {
+					return err
+				}
+				return g.Success(ctx, "mallory@example.com")
+			},
+			email: "mallory@example.com", ip: ip, wantWait: true,
+		},
+		{
+			name: "unlock lifts a lockout",
+			run: func(g *Guard) error {
+				if err := failures("alice@example.com", "198.51.100.7", AccountPolicy.LockoutThreshold)(g); err != nil {
+					return err
+				}
+				return g.Unlock(ctx, "ALICE@example.com")
+			},
+			email: "alice@example.com", ip: ip,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGuard(NewMemoryStore())
+			if err := tt.run(g); err != nil {
+				t.Fatal(err)
+			}
+			wait, err := g.Check(ctx, tt.email, tt.ip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotWait := wait > 0; gotWait != tt.wantWait {
+				t.Errorf("Check(%q, %q) = %v, want a wait: %v", tt.email, tt.ip, wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestGuardLockout(t *testing.T) {
+	ctx := context.Background()
+	g := NewGuard(NewMemoryStore())
+	if err := failures("alice@example.com", "192.0.2.1", AccountPolicy.LockoutThreshold)(g); err != nil {
+		t.Fatal(err)
+	}
+	wait, err := g.Check(ctx, "alice@example.com", "198.51.100.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= AccountPolicy.MaxDelay || wait > AccountPolicy.LockoutDuration {
+		t.Errorf("Check after %d failures = %v, want close to %v", AccountPolicy.LockoutThreshold, wait, AccountPolicy.LockoutDuration)
+	}
+}
+
+func TestThrottleKeepsItsOwnCounts(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	login := NewGuard(store)
+	policy := Policy{FreeAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Hour, LockoutThreshold: 5, LockoutDuration: time.Hour}
+	throttle := NewThrottle(store, "reset:", policy, policy)
+
+	tests := []struct {
+		name      string
+		guard     *Guard
+		email, ip string
+		wantWait  bool
+	}{
+		{"first attempt is free", throttle, "alice@example.com", "192.0.2.1", false},
+		{"second attempt waits", throttle, "alice@example.com", "192.0.2.1", true},
+		{"another account from a fresh address", throttle, "bob@example.com", "198.51.100.7", false},
+		{"logins are not affected", login, "alice@example.com", "192.0.2.1", false},
+	}
+	for _, tt := range tests {
+		if err := tt.guard.Failure(ctx, tt.email, tt.ip); err != nil {
+			t.Fatal(err)
+		}
+		wait, err := tt.guard.Check(ctx, tt.email, tt.ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotWait := wait > 0; gotWait != tt.wantWait {
+			t.Errorf("%s: Check = %v, want a wait: %v", tt.name, wait, tt.wantWait)
+		}
+	}
+}
+
+// failures returns a step recording n failed logins for email from ip.
+func failures(email, ip string, n int) func(g *Guard) error {
+	return func(g *Guard) error {
+		for i := 0; i < n; i++ {
+			if err := g.Failure(context.Background(), email, ip); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// spread returns a step recording n failed logins from ip, each against a
+// different account.
+func spread(ip string, n int) func(g *Guard) error {
+	return func(g *Guard) error {
+		for i := 0; i < n; i++ {
+			if err := g.Failure(context.Background(), fmt.Sprintf("user%d@example.com", i), ip); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
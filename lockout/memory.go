@@ -0,0 +1,64 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process. It suits single instance
+// deployments and tests; records are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current(key), nil
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.current(key)
+	record.Key = key
+	record.Failures++
+	record.Updated_at = time.Now()
+	s.records[key] = record
+	return record.Failures, nil
+}
+
+func (s *MemoryStore) Block(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.current(key)
+	record.Key = key
+	record.Blocked_until = until
+	record.Updated_at = time.Now()
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// current returns the record for key, forgetting it once it has been quiet
+// for recordTTL. Callers must hold s.mu.
+func (s *MemoryStore) current(key string) Record {
+	record, ok := s.records[key]
+	if ok && time.Since(record.Updated_at) > recordTTL {
+		delete(s.records, key)
+		return Record{}
+	}
+	return record
+}
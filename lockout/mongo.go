@@ -0,0 +1,68 @@
+package lockout
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps records in a collection shared by every instance.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{collection: collection}
+}
+
+// EnsureIndexes creates the unique key index and the TTL index that expires
+// quiet records.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "updated_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(recordTTL.Seconds()))},
+	})
+	return err
+}
+
+func (s *MongoStore) Get(ctx context.Context, key string) (Record, error) {
+	var record Record
+	err := s.collection.FindOne(ctx, bson.M{"key": key}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return Record{}, nil
+	}
+	return record, err
+}
+
+func (s *MongoStore) Increment(ctx context.Context, key string) (int, error) {
+	var record Record
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"key": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"updated_at": time.Now()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&record)
+	if err != nil {
+		return 0, err
+	}
+	return record.Failures, nil
+}
+
+func (s *MongoStore) Block(ctx context.Context, key string, until time.Time) error {
+	_, err := s.collection.UpdateOne(ctx,
+		bson.M{"key": key},
+		bson.M{"$set": bson.M{"blocked_until": until, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *MongoStore) Reset(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"key": key})
+	return err
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer does not deliver anything. It appends every message to the file
+// at Path, or to the standard logger when Path is empty, which is enough to
+// follow reset and verification links during development and tests. It must
+// not be used in production, where the logs would hold working tokens.
+type LogMailer struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entry := fmt.Sprintf("--- %s\nTo: %s\nSubject: %s\n\n%s\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	if m.Path == "" {
+		log.Print(entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails such as password reset links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv builds the mailer selected by MAIL_DRIVER. "smtp" sends real mail
+// through SMTP_HOST. "log" only writes messages to MAIL_LOG_PATH, or to the
+// standard logger when that is empty, and is meant for development: the
+// messages carry live reset and verification tokens. There is no default,
+// so a deployment cannot end up logging them by accident.
+func FromEnv() (Mailer, error) {
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		mailer := &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+		if mailer.Host == "" || mailer.From == "" {
+			return nil, errors.New("MAIL_DRIVER smtp needs SMTP_HOST and MAIL_FROM")
+		}
+		if mailer.Port == "" {
+			mailer.Port = "587"
+		}
+		return mailer, nil
+	case "log":
+		return &LogMailer{Path: os.Getenv("MAIL_LOG_PATH")}, nil
+	case "":
+		return nil, errors.New("MAIL_DRIVER is not set, use smtp, or log during development")
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q, use smtp or log", driver)
+	}
+}
@@ -0,0 +1,136 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Mailer
+		wantErr bool
+	}{
+		{"not configured", nil, nil, true},
+		{"unknown driver", map[string]string{"MAIL_DRIVER": "sendmail"}, nil, true},
+		{"log", map[string]string{"MAIL_DRIVER": "log", "MAIL_LOG_PATH": "mail.log"}, &LogMailer{Path: "mail.log"}, false},
+		{"smtp without host", map[string]string{"MAIL_DRIVER": "smtp", "MAIL_FROM": "shop@example.com"}, nil, true},
+		{"smtp without sender", map[string]string{"MAIL_DRIVER": "smtp", "SMTP_HOST": "mail.example.com"}, nil, true},
+		{
+			"smtp",
+			map[string]string{"MAIL_DRIVER": "smtp", "SMTP_HOST": "mail.example.com", "MAIL_FROM": "shop@example.com"},
+			&SMTPMailer{Host: "mail.example.com", Port: "587", From: "shop@example.com"},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"MAIL_DRIVER", "MAIL_LOG_PATH", "SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD", "MAIL_FROM"} {
+				t.Setenv(key, tt.env[key])
+			}
+			got, err := FromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromEnv error = %v, want error: %v", err, tt.wantErr)
+			}
+			switch want := tt.want.(type) {
+			case *LogMailer:
+				if got, ok := got.(*LogMailer); !ok || got.Path != want.Path {
+					t.Errorf("FromEnv = %#v, want %#v", got, want)
+				}
+			case *SMTPMailer:
+				if got, ok := got.(*SMTPMailer); !ok || *got != *want {
+					t.Errorf("FromEnv = %#v, want %#v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSMTPMailerRejectsHeaderInjection(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	m := &SMTPMailer{Host: host, Port: port, From: "shop@example.com"}
+
+	tests := []struct {
+		name    string
+		from    string
+		msg     Message
+		wantErr error
+	}{
+		{"plain", "", Message{To: "alice@example.com", Subject: "Reset your password", Body: "line one\r\nline two"}, nil},
+		{"recipient with CRLF", "", Message{To: "alice@example.com\r\nBcc: mallory@example.com", Subject: "Hi"}, ErrInvalidHeader},
+		{"recipient with LF", "", Message{To: "alice@example.com\nBcc: mallory@example.com", Subject: "Hi"}, ErrInvalidHeader},
+		{"subject with CR", "", Message{To: "alice@example.com", Subject: "Hi\rBcc: mallory@example.com"}, ErrInvalidHeader},
+		{"subject ending the headers", "", Message{To: "alice@example.com", Subject: "Hi\r\n\r\nforged body"}, ErrInvalidHeader},
+		{"sender with a line break", "shop@example.com\nReply-To: mallory@example.com", Message{To: "alice@example.com", Subject: "Hi"}, ErrInvalidHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := *m
+			if tt.from != "" {
+				mailer.From = tt.from
+			}
+			err := mailer.Send(context.Background(), tt.msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// The server hands over the data with line endings as "\n".
+			data := <-received
+			if !strings.Contains(data, "\nTo: alice@example.com\nSubject: Reset your password\n") {
+				t.Errorf("sent headers are wrong:\n%s", data)
+			}
+		})
+	}
+}
+
+// fakeSMTPServer accepts mail on a local port and sends the data of every
+// message it receives to the returned channel.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			text := textproto.NewConn(conn)
+			text.PrintfLine("220 localhost ready")
+			for {
+				line, err := text.ReadLine()
+				if err != nil {
+					break
+				}
+				verb, _, _ := strings.Cut(line, " ")
+				switch strings.ToUpper(verb) {
+				case "EHLO", "HELO":
+					text.PrintfLine("250 localhost")
+				case "DATA":
+					text.PrintfLine("354 go ahead")
+					data, _ := text.ReadDotBytes()
+					received <- string(data)
+					text.PrintfLine("250 queued")
+				case "QUIT":
+					text.PrintfLine("221 bye")
+				default:
+					text.PrintfLine("250 ok")
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String(), received
+}
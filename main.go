@@ -1,9 +1,19 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/SHUBHAM91285/online_book_store/authors"
+	"github.com/SHUBHAM91285/online_book_store/categories"
+	controller "github.com/SHUBHAM91285/online_book_store/controllers"
+	"github.com/SHUBHAM91285/online_book_store/inventory"
+	"github.com/SHUBHAM91285/online_book_store/publishers"
 	routes "github.com/SHUBHAM91285/online_book_store/routes"
+	"github.com/SHUBHAM91285/online_book_store/tokens"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,10 +23,73 @@ func main() {
 		port = "8080"
 	}
 
+	if err := tokens.LoadKeys(); err != nil {
+		log.Fatal(err)
+	}
+	tokens.StartKeyRotation()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := tokens.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := controller.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := controller.MigrateRoles(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := controller.MigrateEmailVerification(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := controller.BootstrapSuperAdmin(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := controller.MigrateStock(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := inventory.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := authors.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := publishers.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := categories.EnsureIndexes(ctx); err != nil {
+		log.Fatal(err)
+	}
+	controller.StartReservationSweeper()
+	controller.StartSuggestionRefresher()
+
 	router := gin.New()
 	router.Use(gin.Logger())
+	if err := router.SetTrustedProxies(trustedProxiesFromEnv()); err != nil {
+		log.Fatal(err)
+	}
 
 	routes.BooksRoutes(router)
+	routes.AuthorRoutes(router)
+	routes.PublisherRoutes(router)
+	routes.CategoryRoutes(router)
 	routes.UserRoutes(router)
+	routes.OrderRoutes(router)
+	routes.WellKnownRoutes(router)
 	router.Run(":" + port)
 }
+
+// trustedProxiesFromEnv reads the comma-separated addresses or CIDRs of the
+// proxies in front of the store from TRUSTED_PROXIES. Only those may set the
+// client address through X-Forwarded-For; with none configured the client
+// address is the peer of the connection, so login throttling cannot be
+// dodged by forging the header.
+func trustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
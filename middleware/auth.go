@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
+	"github.com/SHUBHAM91285/online_book_store/tokens"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	userKey   = "user"
+	claimsKey = "claims"
+)
+
+var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+
+// Authenticate verifies the bearer token on the request, loads the user it
+// was issued for and stores it in the context for the handlers that follow.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header must be a bearer token"})
+			return
+		}
+
+		claims, msg := tokens.VerifyToken(tokenString)
+		if msg != "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var foundUser models.User
+		err := userCollection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&foundUser)
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error occured while loading user"})
+			return
+		}
+
+		c.Set(userKey, foundUser)
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// RequirePermission rejects users whose roles do not grant permission. It
+// must run after Authenticate.
+func RequirePermission(permission rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rbac.HasPermission(CurrentUser(c).Roles, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permission " + string(permission)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireTwoFactor rejects staff accounts that have not enabled two-factor
+// authentication. It must run after Authenticate.
+func RequireTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if rbac.IsStaff(user.Roles) && !user.Two_factor.Enabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "two-factor authentication must be enabled for staff accounts"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user stored by Authenticate.
+func CurrentUser(c *gin.Context) models.User {
+	return c.MustGet(userKey).(models.User)
+}
+
+// CurrentClaims returns the verified token claims stored by Authenticate.
+func CurrentClaims(c *gin.Context) *tokens.SignedDetails {
+	return c.MustGet(claimsKey).(*tokens.SignedDetails)
+}
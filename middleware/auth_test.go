@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
+	"github.com/SHUBHAM91285/online_book_store/tokens"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve runs a request with header through handlers and reports the status
+// and whether the request got past them.
+func serve(header string, handlers ...gin.HandlerFunc) (status int, reached bool) {
+	router := gin.New()
+	handlers = append(handlers, func(c *gin.Context) {
+		reached = true
+		c.Status(http.StatusOK)
+	})
+	router.GET("/", handlers...)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code, reached
+}
+
+// as stands in for Authenticate, storing user for the handlers that follow.
+func as(user models.User) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(userKey, user)
+		c.Next()
+	}
+}
+
+func TestAuthenticateRejects(t *testing.T) {
+	t.Setenv("JWT_KEYS_DIR", t.TempDir())
+	t.Setenv("JWT_ALGORITHM", "EdDSA")
+	t.Setenv("JWT_KEY_ROTATION", "1h")
+	if err := tokens.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := tokens.ChallengeTokenGenerator("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := tokens.TokenGenerator("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// None of these get as far as looking up the user.
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"no header", ""},
+		{"other scheme", "Basic YWxpY2U6c2VjcmV0"},
+		{"empty bearer", "Bearer "},
+		{"lowercase scheme", "bearer " + access},
+		{"not a token", "Bearer not-a-token"},
+		{"tampered token", "Bearer " + access[:len(access)-4] + "AAAA"},
+		{"challenge token", "Bearer " + challenge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reached := serve(tt.header, Authenticate())
+			if status != http.StatusUnauthorized || reached {
+				t.Errorf("status = %d, reached handler: %v, want %d and not reached", status, reached, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []string
+		permission rbac.Permission
+		want       int
+	}{
+		{"customer places orders", []string{"customer"}, rbac.PlaceOrders, http.StatusOK},
+		{"no roles count as customer", nil, rbac.PlaceOrders, http.StatusOK},
+		{"customer cannot manage the catalog", []string{"customer"}, rbac.ManageCatalog, http.StatusForbidden},
+		{"catalog editor cannot manage orders", []string{"catalog_editor"}, rbac.ManageOrders, http.StatusForbidden},
+		{"roles add up", []string{"catalog_editor", "order_manager"}, rbac.ManageOrders, http.StatusOK},
+		{"only super admins manage users", []string{"order_manager"}, rbac.ManageUsers, http.StatusForbidden},
+		{"super admin", []string{"super_admin"}, rbac.ManageUsers, http.StatusOK},
+		{"unknown role grants nothing", []string{"root"}, rbac.PlaceOrders, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reached := serve("", as(models.User{Roles: tt.roles}), RequirePermission(tt.permission))
+			if status != tt.want || reached != (tt.want == http.StatusOK) {
+				t.Errorf("status = %d, reached handler: %v, want %d", status, reached, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireTwoFactor(t *testing.T) {
+	tests := []struct {
+		name      string
+		roles     []string
+		twoFactor bool
+		want      int
+	}{
+		{"customer without two-factor", []string{"customer"}, false, http.StatusOK},
+		{"staff without two-factor", []string{"catalog_editor"}, false, http.StatusForbidden},
+		{"staff with two-factor", []string{"catalog_editor"}, true, http.StatusOK},
+		{"super admin without two-factor", []string{"customer", "super_admin"}, false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := models.User{Roles: tt.roles, Two_factor: models.TwoFactor{Enabled: tt.twoFactor}}
+			if status, _ := serve("", as(user), RequireTwoFactor()); status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	defer func(policy VerificationPolicy) { EmailVerificationPolicy = policy }(EmailVerificationPolicy)
+
+	tests := []struct {
+		policy   VerificationPolicy
+		verified bool
+		want     int
+	}{
+		{VerifyNever, false, http.StatusOK},
+		{VerifyBeforeCheckout, false, http.StatusForbidden},
+		{VerifyBeforeCheckout, true, http.StatusOK},
+		{VerifyBeforeLogin, false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		EmailVerificationPolicy = tt.policy
+		if status, _ := serve("", as(models.User{Email_verified: tt.verified}), RequireVerifiedEmail()); status != tt.want {
+			t.Errorf("policy %s, verified %v: status = %d, want %d", tt.policy, tt.verified, status, tt.want)
+		}
+	}
+}
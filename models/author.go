@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Roles a person can have in the making of a book.
+const (
+	AuthorRoleAuthor      = "author"
+	AuthorRoleEditor      = "editor"
+	AuthorRoleTranslator  = "translator"
+	AuthorRoleIllustrator = "illustrator"
+)
+
+type Author struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Name       string             `json:"name" validate:"required"`
+	Bio        string             `json:"bio"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+}
+
+// BookAuthor credits an author on a book. Name is a copy of the author's
+// name, kept up to date when the author is renamed.
+type BookAuthor struct {
+	Author_id primitive.ObjectID `json:"author_id"`
+	Name      string             `json:"name"`
+	Role      string             `json:"role"`
+}
+
+// Byline returns the credits a book's Author_name lists: its authors, or
+// everyone credited if nobody is credited as author.
+func Byline(credits []BookAuthor) []BookAuthor {
+	var named []BookAuthor
+	for _, credit := range credits {
+		if credit.Role == AuthorRoleAuthor {
+			named = append(named, credit)
+		}
+	}
+	if len(named) == 0 {
+		return credits
+	}
+	return named
+}
@@ -7,15 +7,51 @@ import (
 )
 
 type Books struct {
-	ID          primitive.ObjectID `bson:"_id"`
-	Name        string             `json:"name" validate:"required"`
-	Author_name string             `json:"author_name" validate:"required" default:"anonymous"`
-	Price       int                `json:"price" validate:"required"`
-	Description string             `json:"description"`
-	Author_info string             `json:"author_info"`
-	Publication string             `json:"publication" validate:"required"`
-	Genre       string             `json:"genre"`
-	Category    string             `json:"category" default:"NA"`
-	Created_at  time.Time          `json:"created_at"`
-	Updated_at  time.Time          `json:"updated_at"`
+	ID           primitive.ObjectID   `bson:"_id"`
+	Name         string               `json:"name" validate:"required"`
+	Isbn_10      string               `json:"isbn_10"`
+	Isbn_13      string               `json:"isbn_13"`
+	Authors      []BookAuthor         `json:"authors"`
+	Author_name  string               `json:"author_name" validate:"required" default:"anonymous"`
+	Price        int                  `json:"price" validate:"required"`
+	Description  string               `json:"description"`
+	Author_info  string               `json:"author_info"`
+	Publisher_id *primitive.ObjectID  `json:"publisher_id"`
+	Publication  string               `json:"publication" validate:"required"`
+	Published_at *time.Time           `json:"published_at"`
+	Genre        string               `json:"genre"`
+	Category     string               `json:"category"`
+	Category_ids []primitive.ObjectID `json:"category_ids"`
+	Breadcrumbs  [][]CategoryRef      `json:"breadcrumbs"`
+	Stock        int                  `json:"stock" validate:"min=0"`
+	Reserved     int                  `json:"reserved"`
+	Sold_count   int                  `json:"sold_count"`
+	Cover        *Cover               `json:"cover"`
+	Out_of_stock bool                 `json:"out_of_stock" bson:"-"`
+	Created_at   time.Time            `json:"created_at"`
+	Updated_at   time.Time            `json:"updated_at"`
+}
+
+// Cover is a book's cover image, with thumbnails keyed by size name.
+type Cover struct {
+	Url          string            `json:"url"`
+	Thumbnails   map[string]string `json:"thumbnails"`
+	Content_type string            `json:"content_type"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	Keys         []string          `json:"-"`
+	Updated_at   time.Time         `json:"updated_at"`
+}
+
+// Available returns the copies that are neither sold nor held for a
+// checkout in progress.
+func (b Books) Available() int {
+	return b.Stock - b.Reserved
+}
+
+// WithAvailability fills the fields derived for clients, such as
+// Out_of_stock, which are not stored.
+func (b Books) WithAvailability() Books {
+	b.Out_of_stock = b.Available() <= 0
+	return b
 }
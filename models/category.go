@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Category is a node of the category tree. Ancestors lists the nodes above
+// it, from the root down, so a subtree can be found with one query.
+type Category struct {
+	ID         primitive.ObjectID  `bson:"_id" json:"id"`
+	Name       string              `json:"name" validate:"required"`
+	Parent_id  *primitive.ObjectID `json:"parent_id"`
+	Ancestors  []CategoryRef       `json:"ancestors"`
+	Created_at time.Time           `json:"created_at"`
+	Updated_at time.Time           `json:"updated_at"`
+}
+
+// CategoryRef names a category.
+type CategoryRef struct {
+	ID   primitive.ObjectID `json:"id"`
+	Name string             `json:"name"`
+}
+
+// Breadcrumb returns the path from the root down to c.
+func (c Category) Breadcrumb() []CategoryRef {
+	path := append([]CategoryRef(nil), c.Ancestors...)
+	return append(path, CategoryRef{ID: c.ID, Name: c.Name})
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	OrderPlaced    = "placed"
+	OrderPaid      = "paid"
+	OrderPacked    = "packed"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+type Order struct {
+	ID                primitive.ObjectID `bson:"_id" json:"id"`
+	User_id           primitive.ObjectID `json:"user_id"`
+	Items             []OrderItem        `json:"items"`
+	Total             int                `json:"total"`
+	Status            string             `json:"status"`
+	Payment_intent_id string             `json:"payment_intent_id,omitempty"`
+	Payment_error     string             `json:"payment_error,omitempty"`
+	Refund_error      string             `json:"refund_error,omitempty"`
+	Reserved_until    time.Time          `json:"reserved_until"`
+	History           []StatusChange     `json:"history"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+}
+
+// StatusChange records who moved an order into a status and when.
+type StatusChange struct {
+	From string    `json:"from,omitempty"`
+	To   string    `json:"to"`
+	By   string    `json:"by"`
+	Note string    `json:"note,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// OrderItem is a snapshot of a book at checkout time, so later catalog
+// changes do not alter what the customer bought or paid.
+type OrderItem struct {
+	Book_id  primitive.ObjectID `json:"book_id"`
+	Name     string             `json:"name"`
+	Author   string             `json:"author"`
+	Price    int                `json:"price"`
+	Quantity int                `json:"quantity"`
+	Amount   int                `json:"amount"`
+}
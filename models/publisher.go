@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Publisher struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Name       string             `json:"name" validate:"required"`
+	Country    string             `json:"country"`
+	Website    string             `json:"website" validate:"omitempty,url"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StockAdjustment records a manual change of a book's stock level.
+type StockAdjustment struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Book_id     primitive.ObjectID `json:"book_id"`
+	Delta       int                `json:"delta"`
+	Reason      string             `json:"reason"`
+	By          string             `json:"by"`
+	Stock_after int                `json:"stock_after"`
+	Created_at  time.Time          `json:"created_at"`
+}
+
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+)
+
+// Reservation holds stock for an order between checkout and payment.
+type Reservation struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Order_id   primitive.ObjectID `json:"order_id"`
+	Lines      []ReservedLine     `json:"lines"`
+	Status     string             `json:"status"`
+	Expires_at time.Time          `json:"expires_at"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+}
+
+type ReservedLine struct {
+	Book_id  primitive.ObjectID `json:"book_id"`
+	Quantity int                `json:"quantity"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RefreshToken struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Token_hash string             `json:"token_hash"`
+	Email      string             `json:"email"`
+	Family     string             `json:"family"`
+	Revoked    bool               `json:"revoked"`
+	Expires_at time.Time          `json:"expires_at"`
+	Created_at time.Time          `json:"created_at"`
+}
+
+// RevokedToken either revokes a single access token by its ID or, when
+// Revoked_before is set, every access token issued to Email before then.
+// Revoked_before is in Unix microseconds.
+type RevokedToken struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Jti            string             `json:"jti,omitempty" bson:"jti,omitempty"`
+	Email          string             `json:"email,omitempty" bson:"email,omitempty"`
+	Revoked_before int64              `json:"revoked_before,omitempty" bson:"revoked_before,omitempty"`
+	Expires_at     time.Time          `json:"expires_at"`
+}
+
+// ActionToken is a single-use token mailed to a user to confirm an action
+// such as a password reset.
+type ActionToken struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Token_hash string             `json:"token_hash"`
+	Purpose    string             `json:"purpose"`
+	Email      string             `json:"email"`
+	Used_at    *time.Time         `json:"used_at"`
+	Expires_at time.Time          `json:"expires_at"`
+	Created_at time.Time          `json:"created_at"`
+}
@@ -1,20 +1,38 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type User struct {
-	ID       primitive.ObjectID `bson:"_id"`
-	Name     string             `json:"name" validate:"required"`
-	Email    string             `json:"email" validate:"required"`
-	Password string             `json:"password" validate:"required"`
-	Role     string             `json:"role" enum:"user,admin" default:"user" validate:"required"`
-	Cart     []Cart             `json:"cart"`
+	ID             primitive.ObjectID `bson:"_id"`
+	Name           string             `json:"name" validate:"required"`
+	Email          string             `json:"email" validate:"required,email"`
+	Password       string             `json:"password" validate:"required"`
+	Roles          []string           `json:"roles"`
+	Cart           []Cart             `json:"cart"`
+	Email_verified bool               `json:"email_verified"`
+	Verified_at    *time.Time         `json:"verified_at"`
+	Two_factor     TwoFactor          `json:"-"`
+}
+
+// TwoFactor holds the TOTP state of an account. None of it is ever sent to
+// or accepted from clients directly.
+type TwoFactor struct {
+	Enabled        bool
+	Secret         string
+	Pending_secret string
+	// Last_step is the TOTP time step of the last accepted code, so a code
+	// cannot be replayed within its validity window.
+	Last_step      int64
+	Recovery_codes []string
 }
 
 type Cart struct {
 	ID       primitive.ObjectID `json:"id"`
+	Book_id  primitive.ObjectID `json:"book_id"`
 	Name     string             `json:"name" validate:"required"`
 	Price    int                `json:"price" validate:"required"`
 	Quantity int                `json:"quantity" default:"1"`
@@ -0,0 +1,39 @@
+// Package orders owns the order lifecycle: which status changes are legal
+// and how they are applied.
+package orders
+
+import "github.com/SHUBHAM91285/online_book_store/models"
+
+// transitions lists, for every status, the statuses an order may move to
+// next. Cancelled and refunded orders are final.
+var transitions = map[string][]string{
+	models.OrderPlaced:    {models.OrderPaid, models.OrderCancelled},
+	models.OrderPaid:      {models.OrderPacked, models.OrderRefunded},
+	models.OrderPacked:    {models.OrderShipped, models.OrderRefunded},
+	models.OrderShipped:   {models.OrderDelivered, models.OrderRefunded},
+	models.OrderDelivered: {models.OrderRefunded},
+	models.OrderCancelled: {},
+	models.OrderRefunded:  {},
+}
+
+// IsStatus reports whether status is a known order status.
+func IsStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransition reports whether an order may move from one status to the
+// other.
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStatuses returns the statuses an order in status may move to.
+func NextStatuses(status string) []string {
+	return append([]string{}, transitions[status]...)
+}
@@ -0,0 +1,96 @@
+package orders
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+)
+
+var statuses = []string{
+	models.OrderPlaced,
+	models.OrderPaid,
+	models.OrderPacked,
+	models.OrderShipped,
+	models.OrderDelivered,
+	models.OrderCancelled,
+	models.OrderRefunded,
+}
+
+func TestCanTransition(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{models.OrderPlaced, models.OrderPaid}:        true,
+		{models.OrderPlaced, models.OrderCancelled}:   true,
+		{models.OrderPaid, models.OrderPacked}:        true,
+		{models.OrderPaid, models.OrderRefunded}:      true,
+		{models.OrderPacked, models.OrderShipped}:     true,
+		{models.OrderPacked, models.OrderRefunded}:    true,
+		{models.OrderShipped, models.OrderDelivered}:  true,
+		{models.OrderShipped, models.OrderRefunded}:   true,
+		{models.OrderDelivered, models.OrderRefunded}: true,
+	}
+	// Every pair of statuses not listed above, including staying in the
+	// same status, must be refused.
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestCanTransitionUnknownStatus(t *testing.T) {
+	tests := []struct {
+		from, to string
+	}{
+		{"lost", models.OrderPaid},
+		{models.OrderPlaced, "lost"},
+		{"", models.OrderPlaced},
+	}
+	for _, tt := range tests {
+		if CanTransition(tt.from, tt.to) {
+			t.Errorf("CanTransition(%q, %q) = true, want false", tt.from, tt.to)
+		}
+	}
+}
+
+func TestIsStatus(t *testing.T) {
+	for _, status := range statuses {
+		if !IsStatus(status) {
+			t.Errorf("IsStatus(%q) = false, want true", status)
+		}
+	}
+	for _, status := range []string{"", "lost", "Placed"} {
+		if IsStatus(status) {
+			t.Errorf("IsStatus(%q) = true, want false", status)
+		}
+	}
+}
+
+func TestNextStatuses(t *testing.T) {
+	tests := []struct {
+		status string
+		want   []string
+	}{
+		{models.OrderPlaced, []string{models.OrderPaid, models.OrderCancelled}},
+		{models.OrderDelivered, []string{models.OrderRefunded}},
+		{models.OrderCancelled, []string{}},
+		{models.OrderRefunded, []string{}},
+		{"lost", []string{}},
+	}
+	for _, tt := range tests {
+		got := NextStatuses(tt.status)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NextStatuses(%s) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+
+	// Callers get a copy they may change freely.
+	next := NextStatuses(models.OrderPlaced)
+	next[0] = models.OrderShipped
+	if !CanTransition(models.OrderPlaced, models.OrderPaid) || CanTransition(models.OrderPlaced, models.OrderShipped) {
+		t.Error("changing the result of NextStatuses changed the state machine")
+	}
+}
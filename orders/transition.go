@@ -0,0 +1,70 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotFound = errors.New("order not found")
+	// ErrConflict means the order changed status while the transition was
+	// being applied; the caller may reload it and try again.
+	ErrConflict = errors.New("order status changed concurrently")
+)
+
+// IllegalTransitionError is returned for status changes the state machine
+// does not allow.
+type IllegalTransitionError struct {
+	From, To string
+}
+
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+var ordersCollection *mongo.Collection = database.OpenCollection(database.Client, "orders")
+
+// Transition moves the order to status to on behalf of actor and records the
+// change in its history. It only succeeds if the order is still in the
+// status it was read in, so concurrent transitions cannot both apply.
+func Transition(ctx context.Context, orderID primitive.ObjectID, to, actor, note string) (models.Order, error) {
+	var order models.Order
+	err := ordersCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return models.Order{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Order{}, err
+	}
+	if !CanTransition(order.Status, to) {
+		return models.Order{}, &IllegalTransitionError{From: order.Status, To: to}
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	change := models.StatusChange{From: order.Status, To: to, By: actor, Note: note, At: now}
+	var updated models.Order
+	err = ordersCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": orderID, "status": order.Status},
+		bson.M{
+			"$set":  bson.M{"status": to, "updated_at": now},
+			"$push": bson.M{"history": change},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return models.Order{}, ErrConflict
+	}
+	if err != nil {
+		return models.Order{}, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,247 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader carries the webhook signature, formatted as
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<payload>">".
+const SignatureHeader = "Payment-Signature"
+
+// signatureTolerance bounds how old a signed webhook may be, which limits
+// replays of captured requests.
+const signatureTolerance = 5 * time.Minute
+
+// FakeProvider is an in-process gateway for development and tests. It
+// behaves deterministically: IDs are sequential and every payment succeeds
+// unless its amount ends in 13, which is declined.
+//
+// Events are signed with Secret and POSTed to WebhookURL, exercising the
+// same verification path a real provider would. Without a WebhookURL they
+// are only kept for SentEvents.
+type FakeProvider struct {
+	Secret     string
+	WebhookURL string
+
+	mu      sync.Mutex
+	nextID  int
+	intents map[string]*Intent
+	events  []Event
+}
+
+func NewFakeProvider(secret, webhookURL string) *FakeProvider {
+	return &FakeProvider{Secret: secret, WebhookURL: webhookURL, intents: map[string]*Intent{}}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateIntent(ctx context.Context, orderID string, amount int, currency string) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := p.newID("pi")
+	intent := &Intent{
+		ID:            id,
+		Order_id:      orderID,
+		Amount:        amount,
+		Currency:      currency,
+		Status:        IntentRequiresConfirmation,
+		Client_secret: id + "_secret",
+	}
+	p.intents[id] = intent
+	return *intent, nil
+}
+
+func (p *FakeProvider) Intent(ctx context.Context, intentID string) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+	return *intent, nil
+}
+
+func (p *FakeProvider) Confirm(ctx context.Context, intentID string) (Intent, error) {
+	p.mu.Lock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		p.mu.Unlock()
+		return Intent{}, ErrIntentNotFound
+	}
+	if intent.Status != IntentRequiresConfirmation {
+		p.mu.Unlock()
+		return Intent{}, ErrInvalidState
+	}
+	eventType := EventPaymentAuthorized
+	intent.Status = IntentAuthorized
+	if intent.Amount%100 == 13 {
+		eventType = EventPaymentFailed
+		intent.Status = IntentFailed
+	}
+	event := Event{
+		ID:        p.newID("evt"),
+		Type:      eventType,
+		Intent_id: intent.ID,
+		Order_id:  intent.Order_id,
+		Amount:    intent.Amount,
+	}
+	p.events = append(p.events, event)
+	result := *intent
+	p.mu.Unlock()
+
+	p.deliver(event)
+	return result, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, intentID string) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+	if intent.Status == IntentCaptured {
+		return *intent, nil
+	}
+	if intent.Status != IntentAuthorized {
+		return Intent{}, ErrInvalidState
+	}
+	intent.Status = IntentCaptured
+	return *intent, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, intentID string, amount int) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+	if intent.Status != IntentCaptured || amount <= 0 || intent.Refunded+amount > intent.Amount {
+		return Intent{}, ErrInvalidState
+	}
+	intent.Refunded += amount
+	if intent.Refunded == intent.Amount {
+		intent.Status = IntentRefunded
+	}
+	return *intent, nil
+}
+
+func (p *FakeProvider) Cancel(ctx context.Context, intentID string) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+	switch intent.Status {
+	case IntentRequiresConfirmation, IntentAuthorized, IntentFailed:
+		intent.Status = IntentCanceled
+	case IntentCanceled:
+	default:
+		return Intent{}, ErrInvalidState
+	}
+	return *intent, nil
+}
+
+func (p *FakeProvider) VerifyWebhook(payload []byte, signature string) (Event, error) {
+	var timestamp, mac string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			mac = value
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || mac == "" {
+		return Event{}, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return Event{}, ErrInvalidSignature
+	}
+	expected := p.sign(timestamp, payload)
+	if !hmac.Equal([]byte(expected), []byte(mac)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// SentEvents returns every event the fake has emitted so far.
+func (p *FakeProvider) SentEvents() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Event(nil), p.events...)
+}
+
+// SignatureFor returns the signature header value for payload, as the fake
+// gateway would send it.
+func (p *FakeProvider) SignatureFor(payload []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + p.sign(timestamp, payload)
+}
+
+func (p *FakeProvider) sign(timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *FakeProvider) deliver(event Event) {
+	if p.WebhookURL == "" {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Println("fake payment provider: failed to encode event:", err)
+		return
+	}
+	signature := p.SignatureFor(payload, time.Now())
+	go func() {
+		request, err := http.NewRequest(http.MethodPost, p.WebhookURL, bytes.NewReader(payload))
+		if err != nil {
+			log.Println("fake payment provider: failed to build webhook request:", err)
+			return
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set(SignatureHeader, signature)
+		client := &http.Client{Timeout: 10 * time.Second}
+		response, err := client.Do(request)
+		if err != nil {
+			log.Println("fake payment provider: webhook delivery failed:", err)
+			return
+		}
+		response.Body.Close()
+		if response.StatusCode >= 300 {
+			log.Println("fake payment provider: webhook answered", response.Status)
+		}
+	}()
+}
+
+// newID returns the next sequential ID with prefix. Callers must hold p.mu.
+func (p *FakeProvider) newID(prefix string) string {
+	p.nextID++
+	return fmt.Sprintf("%s_fake_%06d", prefix, p.nextID)
+}
@@ -0,0 +1,172 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFakeProviderLifecycle(t *testing.T) {
+	ctx := context.Background()
+	// Each step runs against the intent left by the previous ones.
+	type step struct {
+		name       string
+		do         func(p *FakeProvider, id string) (Intent, error)
+		wantErr    error
+		wantStatus string
+	}
+	confirm := func(p *FakeProvider, id string) (Intent, error) { return p.Confirm(ctx, id) }
+	capture := func(p *FakeProvider, id string) (Intent, error) { return p.Capture(ctx, id) }
+	cancel := func(p *FakeProvider, id string) (Intent, error) { return p.Cancel(ctx, id) }
+	refund := func(amount int) func(p *FakeProvider, id string) (Intent, error) {
+		return func(p *FakeProvider, id string) (Intent, error) { return p.Refund(ctx, id, amount) }
+	}
+
+	tests := []struct {
+		name   string
+		amount int
+		steps  []step
+	}{
+		{"paid and fully refunded", 1000, []step{
+			{"capture before confirm", capture, ErrInvalidState, ""},
+			{"confirm", confirm, nil, IntentAuthorized},
+			{"confirm twice", confirm, ErrInvalidState, ""},
+			{"capture", capture, nil, IntentCaptured},
+			{"capture again", capture, nil, IntentCaptured},
+			{"partial refund", refund(400), nil, IntentCaptured},
+			{"refund more than is left", refund(700), ErrInvalidState, ""},
+			{"refund the rest", refund(600), nil, IntentRefunded},
+			{"refund after full refund", refund(1), ErrInvalidState, ""},
+		}},
+		{"declined", 1013, []step{
+			{"confirm", confirm, nil, IntentFailed},
+			{"capture", capture, ErrInvalidState, ""},
+			{"cancel", cancel, nil, IntentCanceled},
+		}},
+		{"cancelled before confirmation", 500, []step{
+			{"cancel", cancel, nil, IntentCanceled},
+			{"cancel again", cancel, nil, IntentCanceled},
+			{"confirm", confirm, ErrInvalidState, ""},
+		}},
+		{"cancelled after authorization", 500, []step{
+			{"confirm", confirm, nil, IntentAuthorized},
+			{"cancel", cancel, nil, IntentCanceled},
+			{"capture", capture, ErrInvalidState, ""},
+		}},
+		{"captured cannot be cancelled", 500, []step{
+			{"confirm", confirm, nil, IntentAuthorized},
+			{"capture", capture, nil, IntentCaptured},
+			{"cancel", cancel, ErrInvalidState, ""},
+			{"refund nothing", refund(0), ErrInvalidState, ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewFakeProvider("secret", "")
+			intent, err := p.CreateIntent(ctx, "order-1", tt.amount, "USD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if intent.Status != IntentRequiresConfirmation || intent.Amount != tt.amount || intent.Client_secret == "" {
+				t.Fatalf("CreateIntent = %+v", intent)
+			}
+			for _, s := range tt.steps {
+				got, err := s.do(p, intent.ID)
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("%s: error = %v, want %v", s.name, err, s.wantErr)
+				}
+				if err == nil && got.Status != s.wantStatus {
+					t.Fatalf("%s: status = %s, want %s", s.name, got.Status, s.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestFakeProviderUnknownIntent(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakeProvider("secret", "")
+	calls := map[string]func() (Intent, error){
+		"Intent":  func() (Intent, error) { return p.Intent(ctx, "pi_missing") },
+		"Confirm": func() (Intent, error) { return p.Confirm(ctx, "pi_missing") },
+		"Capture": func() (Intent, error) { return p.Capture(ctx, "pi_missing") },
+		"Refund":  func() (Intent, error) { return p.Refund(ctx, "pi_missing", 1) },
+		"Cancel":  func() (Intent, error) { return p.Cancel(ctx, "pi_missing") },
+	}
+	for name, call := range calls {
+		if _, err := call(); !errors.Is(err, ErrIntentNotFound) {
+			t.Errorf("%s of an unknown intent: error = %v, want %v", name, err, ErrIntentNotFound)
+		}
+	}
+}
+
+func TestFakeProviderEvents(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakeProvider("secret", "")
+	paid, _ := p.CreateIntent(ctx, "order-1", 1000, "USD")
+	declined, _ := p.CreateIntent(ctx, "order-2", 2013, "USD")
+	if paid.ID == declined.ID {
+		t.Fatalf("two intents share the ID %s", paid.ID)
+	}
+	p.Confirm(ctx, paid.ID)
+	p.Confirm(ctx, declined.ID)
+
+	events := p.SentEvents()
+	want := []Event{
+		{Type: EventPaymentAuthorized, Intent_id: paid.ID, Order_id: "order-1", Amount: 1000},
+		{Type: EventPaymentFailed, Intent_id: declined.ID, Order_id: "order-2", Amount: 2013},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("SentEvents = %+v, want %d events", events, len(want))
+	}
+	for i, event := range events {
+		if event.ID == "" {
+			t.Errorf("event %d has no ID", i)
+		}
+		event.ID = ""
+		if event != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+}
+
+func TestFakeProviderVerifyWebhook(t *testing.T) {
+	p := NewFakeProvider("secret", "")
+	event := Event{ID: "evt_1", Type: EventPaymentAuthorized, Intent_id: "pi_1", Order_id: "order-1", Amount: 1000}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		wantErr   bool
+	}{
+		{"valid", payload, p.SignatureFor(payload, now), false},
+		{"slightly in the future", payload, p.SignatureFor(payload, now.Add(time.Minute)), false},
+		{"tampered payload", []byte(`{"id":"evt_1","amount":1}`), p.SignatureFor(payload, now), true},
+		{"too old", payload, p.SignatureFor(payload, now.Add(-signatureTolerance-time.Minute)), true},
+		{"too far in the future", payload, p.SignatureFor(payload, now.Add(signatureTolerance+time.Minute)), true},
+		{"other secret", payload, NewFakeProvider("other", "").SignatureFor(payload, now), true},
+		{"no signature", payload, "", true},
+		{"missing mac", payload, "t=" + strconv.FormatInt(now.Unix(), 10), true},
+		{"garbled", payload, "v1=abc,t=soon", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.VerifyWebhook(tt.payload, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyWebhook error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && got != event {
+				t.Errorf("VerifyWebhook = %+v, want %+v", got, event)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Package payments abstracts the payment gateway. Orders are paid through a
+// PaymentProvider and only count as paid once the provider reports it
+// through a verified webhook.
+package payments
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	IntentRequiresConfirmation = "requires_confirmation"
+	IntentAuthorized           = "authorized"
+	IntentCaptured             = "captured"
+	IntentRefunded             = "refunded"
+	IntentFailed               = "failed"
+	IntentCanceled             = "canceled"
+)
+
+const (
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentFailed     = "payment.failed"
+)
+
+var (
+	ErrIntentNotFound   = errors.New("payment intent not found")
+	ErrInvalidState     = errors.New("payment intent is not in a state that allows this operation")
+	ErrInvalidSignature = errors.New("webhook signature is invalid")
+)
+
+// Intent is a single attempt to collect Amount for an order.
+type Intent struct {
+	ID            string `json:"id"`
+	Order_id      string `json:"order_id"`
+	Amount        int    `json:"amount"`
+	Currency      string `json:"currency"`
+	Status        string `json:"status"`
+	Client_secret string `json:"client_secret,omitempty"`
+	Refunded      int    `json:"refunded,omitempty"`
+}
+
+// Event is a webhook notification sent by the provider.
+type Event struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Intent_id string `json:"intent_id"`
+	Order_id  string `json:"order_id"`
+	Amount    int    `json:"amount"`
+}
+
+type PaymentProvider interface {
+	// Name identifies the provider in order history entries.
+	Name() string
+	CreateIntent(ctx context.Context, orderID string, amount int, currency string) (Intent, error)
+	// Intent looks up an intent created earlier.
+	Intent(ctx context.Context, intentID string) (Intent, error)
+	// Confirm authorizes the payment. The outcome is reported
+	// asynchronously through a webhook.
+	Confirm(ctx context.Context, intentID string) (Intent, error)
+	// Capture collects an authorized payment.
+	Capture(ctx context.Context, intentID string) (Intent, error)
+	Refund(ctx context.Context, intentID string, amount int) (Intent, error)
+	// Cancel voids an intent that has not been captured, releasing any
+	// authorization on the customer's funds.
+	Cancel(ctx context.Context, intentID string) (Intent, error)
+	// VerifyWebhook checks the signature of a webhook request and decodes
+	// its event.
+	VerifyWebhook(payload []byte, signature string) (Event, error)
+}
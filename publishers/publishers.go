@@ -0,0 +1,210 @@
+// Package publishers keeps the publishers books are linked to, and the
+// publisher name books carry in Publication, consistent with each other.
+package publishers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotFound  = errors.New("publisher not found")
+	ErrSelfMerge = errors.New("a publisher cannot be merged into itself")
+)
+
+var publishersCollection *mongo.Collection = database.OpenCollection(database.Client, "publishers")
+var booksCollection *mongo.Collection = database.OpenCollection(database.Client, "books")
+
+// Find returns the publisher with id.
+func Find(ctx context.Context, id primitive.ObjectID) (models.Publisher, error) {
+	var publisher models.Publisher
+	err := publishersCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&publisher)
+	if err == mongo.ErrNoDocuments {
+		return publisher, ErrNotFound
+	}
+	return publisher, err
+}
+
+// Refresh copies the name of the publisher with id onto its books.
+func Refresh(ctx context.Context, id primitive.ObjectID) error {
+	publisher, err := Find(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = booksCollection.UpdateMany(ctx,
+		bson.M{"publisher_id": id},
+		bson.M{"$set": bson.M{"publication": publisher.Name}})
+	return err
+}
+
+// Merge moves the books of the publishers in from to the publisher into,
+// then deletes them. Books are moved before any publisher is deleted, so a
+// merge that fails part way can simply be run again.
+func Merge(ctx context.Context, into primitive.ObjectID, from []primitive.ObjectID) (int64, error) {
+	for _, id := range from {
+		if id == into {
+			return 0, ErrSelfMerge
+		}
+	}
+	target, err := Find(ctx, into)
+	if err != nil {
+		return 0, err
+	}
+	count, err := publishersCollection.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": from}})
+	if err != nil {
+		return 0, err
+	}
+	if count != int64(len(uniq(from))) {
+		return 0, ErrNotFound
+	}
+
+	result, err := booksCollection.UpdateMany(ctx,
+		bson.M{"publisher_id": bson.M{"$in": from}},
+		bson.M{"$set": bson.M{"publisher_id": into, "publication": target.Name, "updated_at": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := publishersCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": from}}); err != nil {
+		return result.ModifiedCount, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func uniq(ids []primitive.ObjectID) []primitive.ObjectID {
+	seen := map[primitive.ObjectID]bool{}
+	var unique []primitive.ObjectID
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// HasBooks reports whether any book is linked to the publisher with id.
+func HasBooks(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	count, err := booksCollection.CountDocuments(ctx, bson.M{"publisher_id": id}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// MigrationReport counts what Migrate did.
+type MigrationReport struct {
+	Publishers_created int `json:"publishers_created"`
+	Books_linked       int `json:"books_linked"`
+}
+
+// Migrate links every book without a publisher to the publisher named by
+// its Publication, creating publishers on first sight. Names are matched
+// exactly after trimming spaces; duplicates that differ in spelling can be
+// merged afterwards. Only unlinked books are touched, so Migrate can be run
+// again after imports.
+func Migrate(ctx context.Context) (MigrationReport, error) {
+	var report MigrationReport
+	cursor, err := booksCollection.Find(ctx,
+		bson.M{"publication": bson.M{"$gt": ""}, "publisher_id": nil},
+		options.Find().SetProjection(bson.M{"publication": 1}))
+	if err != nil {
+		return report, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var book models.Books
+		if err := cursor.Decode(&book); err != nil {
+			return report, err
+		}
+		name := strings.TrimSpace(book.Publication)
+		if name == "" {
+			continue
+		}
+
+		publisher, created, err := findOrCreate(ctx, name)
+		if err != nil {
+			return report, err
+		}
+		if created {
+			report.Publishers_created++
+		}
+
+		_, err = booksCollection.UpdateOne(ctx,
+			bson.M{"_id": book.ID},
+			bson.M{"$set": bson.M{"publisher_id": publisher.ID, "publication": publisher.Name}})
+		if err != nil {
+			return report, err
+		}
+		report.Books_linked++
+	}
+	return report, cursor.Err()
+}
+
+// Resolve returns the publisher called name, creating it on first sight the
+// way Migrate does, for importers that link books as they write them.
+func Resolve(ctx context.Context, name string) (models.Publisher, error) {
+	publisher, _, err := findOrCreate(ctx, strings.TrimSpace(name))
+	return publisher, err
+}
+
+// findOrCreate returns the publisher called name, creating it if there is
+// none.
+func findOrCreate(ctx context.Context, name string) (models.Publisher, bool, error) {
+	now := time.Now()
+	result, err := publishersCollection.UpdateOne(ctx,
+		bson.M{"name": name},
+		bson.M{"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "country": "", "website": "", "created_at": now, "updated_at": now}},
+		options.Update().SetUpsert(true))
+	// A concurrent upsert of the same name trips the unique index; the
+	// publisher it created is the one wanted.
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return models.Publisher{}, false, err
+	}
+	created := err == nil && result.UpsertedID != nil
+	var publisher models.Publisher
+	err = publishersCollection.FindOne(ctx, bson.M{"name": name}).Decode(&publisher)
+	return publisher, created, err
+}
+
+// EnsureIndexes creates the index used to list a publisher's books, and the
+// unique index on publisher names. Publishers that already share a name
+// have to be merged before the unique index can be built; until then names
+// keep a plain index and a warning is logged on every start.
+func EnsureIndexes(ctx context.Context) error {
+	if _, err := booksCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "publisher_id", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	// Earlier versions indexed names without making them unique.
+	specs, err := publishersCollection.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if spec.Name == "name_1" && (spec.Unique == nil || !*spec.Unique) {
+			if _, err := publishersCollection.Indexes().DropOne(ctx, spec.Name); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = publishersCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		log.Println("publisher names are not unique yet, merge the duplicates through /admin/publishers/merge:", err)
+		_, err = publishersCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "name", Value: 1}},
+		})
+	}
+	return err
+}
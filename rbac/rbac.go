@@ -0,0 +1,92 @@
+package rbac
+
+import "sort"
+
+type Role string
+type Permission string
+
+const (
+	Customer      Role = "customer"
+	CatalogEditor Role = "catalog_editor"
+	OrderManager  Role = "order_manager"
+	SuperAdmin    Role = "super_admin"
+)
+
+const (
+	PlaceOrders   Permission = "orders:place"
+	ManageCatalog Permission = "catalog:manage"
+	ManageOrders  Permission = "orders:manage"
+	ManageUsers   Permission = "users:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	Customer:      {PlaceOrders},
+	CatalogEditor: {PlaceOrders, ManageCatalog},
+	OrderManager:  {PlaceOrders, ManageOrders},
+	SuperAdmin:    {PlaceOrders, ManageCatalog, ManageOrders, ManageUsers},
+}
+
+// IsValid reports whether role is one of the known roles.
+func IsValid(role string) bool {
+	_, ok := rolePermissions[Role(role)]
+	return ok
+}
+
+// PermissionsOf returns the permissions for role, or nil for unknown roles.
+func PermissionsOf(role Role) []Permission {
+	return rolePermissions[role]
+}
+
+// Permissions returns the union of the permissions granted by roles, sorted.
+// Users without any role are treated as customers.
+func Permissions(roles []string) []Permission {
+	if len(roles) == 0 {
+		roles = []string{string(Customer)}
+	}
+	seen := map[Permission]bool{}
+	var permissions []Permission
+	for _, role := range roles {
+		for _, permission := range rolePermissions[Role(role)] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	return permissions
+}
+
+// HasPermission reports whether any of roles grants permission.
+func HasPermission(roles []string, permission Permission) bool {
+	for _, p := range Permissions(roles) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles lists every known role, sorted by name.
+func Roles() []Role {
+	roles := make([]Role, 0, len(rolePermissions))
+	for role := range rolePermissions {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	return roles
+}
+
+// IsStaff reports whether roles grant anything beyond what customers get.
+func IsStaff(roles []string) bool {
+	customer := map[Permission]bool{}
+	for _, permission := range rolePermissions[Customer] {
+		customer[permission] = true
+	}
+	for _, permission := range Permissions(roles) {
+		if !customer[permission] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []string
+		want  []Permission
+	}{
+		{"no roles count as customer", nil, []Permission{PlaceOrders}},
+		{"customer", []string{"customer"}, []Permission{PlaceOrders}},
+		{"catalog editor", []string{"catalog_editor"}, []Permission{ManageCatalog, PlaceOrders}},
+		{"roles are merged without duplicates", []string{"catalog_editor", "order_manager", "customer"}, []Permission{ManageCatalog, ManageOrders, PlaceOrders}},
+		{"super admin", []string{"super_admin"}, []Permission{ManageCatalog, ManageOrders, PlaceOrders, ManageUsers}},
+		{"unknown roles grant nothing", []string{"root"}, nil},
+		{"unknown roles are ignored beside known ones", []string{"root", "customer"}, []Permission{PlaceOrders}},
+		{"roles are case sensitive", []string{"Super_Admin"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Permissions(tt.roles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Permissions(%q) = %q, want %q", tt.roles, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		roles      []string
+		permission Permission
+		want       bool
+	}{
+		{nil, PlaceOrders, true},
+		{nil, ManageCatalog, false},
+		{[]string{"customer"}, ManageUsers, false},
+		{[]string{"order_manager"}, ManageOrders, true},
+		{[]string{"order_manager"}, ManageCatalog, false},
+		{[]string{"catalog_editor", "order_manager"}, ManageUsers, false},
+		{[]string{"super_admin"}, ManageUsers, true},
+		{[]string{"root"}, PlaceOrders, false},
+		{[]string{"super_admin"}, Permission("users:delete"), false},
+	}
+	for _, tt := range tests {
+		if got := HasPermission(tt.roles, tt.permission); got != tt.want {
+			t.Errorf("HasPermission(%q, %s) = %v, want %v", tt.roles, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestIsStaff(t *testing.T) {
+	tests := []struct {
+		roles []string
+		want  bool
+	}{
+		{nil, false},
+		{[]string{"customer"}, false},
+		{[]string{"root"}, false},
+		{[]string{"customer", "catalog_editor"}, true},
+		{[]string{"order_manager"}, true},
+		{[]string{"super_admin"}, true},
+	}
+	for _, tt := range tests {
+		if got := IsStaff(tt.roles); got != tt.want {
+			t.Errorf("IsStaff(%q) = %v, want %v", tt.roles, got, tt.want)
+		}
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	for _, role := range Roles() {
+		if !IsValid(string(role)) {
+			t.Errorf("IsValid(%q) = false for a listed role", role)
+		}
+	}
+	for _, role := range []string{"", "admin", "root", "SUPER_ADMIN"} {
+		if IsValid(role) {
+			t.Errorf("IsValid(%q) = true, want false", role)
+		}
+	}
+	want := []Role{CatalogEditor, Customer, OrderManager, SuperAdmin}
+	if got := Roles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Roles() = %q, want %q", got, want)
+	}
+}
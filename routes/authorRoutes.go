@@ -0,0 +1,19 @@
+package routes
+
+import (
+	controller "github.com/SHUBHAM91285/online_book_store/controllers"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AuthorRoutes(incomingRoutes *gin.Engine) {
+	public := incomingRoutes.Group("/")
+	public.GET("/authors/:author_id", controller.GetAuthor())
+
+	admin := incomingRoutes.Group("/admin", middleware.Authenticate(), middleware.RequireTwoFactor(), middleware.RequirePermission(rbac.ManageCatalog))
+	admin.POST("/author", controller.AddAuthor())
+	admin.PATCH("/author/:author_id", controller.UpdateAuthor())
+	admin.DELETE("/author/:author_id", controller.DeleteAuthor())
+}
@@ -2,14 +2,30 @@ package routes
 
 import (
 	controller "github.com/SHUBHAM91285/online_book_store/controllers"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
 func BooksRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/books", controller.GetBooks())
-	incomingRoutes.GET("/books/:parameter", controller.GetBookByParameter())
-	incomingRoutes.POST("/admin/book", controller.AddBook())
-	incomingRoutes.PATCH("/admin/book/:book_id", controller.UpdateBookInfo())
-	incomingRoutes.DELETE("/admin/book/:book_id", controller.DeleteBook())
+	public := incomingRoutes.Group("/")
+	public.GET("/books", controller.GetBooks())
+	public.GET("/books/search", controller.SearchBooks())
+	public.GET("/books/suggest", controller.SuggestBooks())
+	public.GET("/books/:parameter", controller.GetBookByParameter())
+	public.GET("/books/isbn/:isbn", controller.GetBookByISBN())
+	if dir := controller.LocalMediaDir(); dir != "" {
+		public.Static("/media", dir)
+	}
+
+	admin := incomingRoutes.Group("/admin", middleware.Authenticate(), middleware.RequireTwoFactor(), middleware.RequirePermission(rbac.ManageCatalog))
+	admin.POST("/book", controller.AddBook())
+	admin.POST("/books/import", controller.ImportBooks())
+	admin.GET("/books/export", controller.ExportBooks())
+	admin.PATCH("/book/:book_id", controller.UpdateBookInfo())
+	admin.DELETE("/book/:book_id", controller.DeleteBook())
+	admin.PATCH("/book/:book_id/stock", controller.AdjustStock())
+	admin.POST("/book/:book_id/cover", controller.UploadCover())
+	admin.DELETE("/book/:book_id/cover", controller.RemoveCover())
 }
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	controller "github.com/SHUBHAM91285/online_book_store/controllers"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CategoryRoutes(incomingRoutes *gin.Engine) {
+	public := incomingRoutes.Group("/")
+	public.GET("/categories", controller.GetCategoryTree())
+	public.GET("/categories/:category_id", controller.GetCategory())
+	public.GET("/categories/:category_id/books", controller.GetCategoryBooks())
+
+	admin := incomingRoutes.Group("/admin", middleware.Authenticate(), middleware.RequireTwoFactor(), middleware.RequirePermission(rbac.ManageCatalog))
+	admin.POST("/category", controller.AddCategory())
+	admin.PATCH("/category/:category_id", controller.RenameCategory())
+	admin.POST("/category/:category_id/move", controller.MoveCategory())
+	admin.DELETE("/category/:category_id", controller.DeleteCategory())
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	controller "github.com/SHUBHAM91285/online_book_store/controllers"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func OrderRoutes(incomingRoutes *gin.Engine) {
+	customer := incomingRoutes.Group("/", middleware.Authenticate(), middleware.RequirePermission(rbac.PlaceOrders))
+	customer.POST("/checkout", middleware.RequireVerifiedEmail(), controller.Checkout())
+	customer.GET("/orders", controller.GetOrders())
+	customer.GET("/orders/:id", controller.GetOrder())
+	customer.POST("/orders/:id/pay", controller.PayOrder())
+	customer.POST("/orders/:id/pay/confirm", controller.ConfirmPayment())
+
+	incomingRoutes.POST("/payments/webhook", controller.PaymentWebhook())
+
+	admin := incomingRoutes.Group("/admin", middleware.Authenticate(), middleware.RequireTwoFactor(), middleware.RequirePermission(rbac.ManageOrders))
+	admin.GET("/orders", controller.ListOrdersByStatus())
+	admin.PATCH("/orders/:id/status", controller.UpdateOrderStatus())
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	controller "github.com/SHUBHAM91285/online_book_store/controllers"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PublisherRoutes(incomingRoutes *gin.Engine) {
+	public := incomingRoutes.Group("/")
+	public.GET("/publishers/:publisher_id", controller.GetPublisher())
+	public.GET("/publishers/:publisher_id/books", controller.GetPublisherBooks())
+
+	admin := incomingRoutes.Group("/admin", middleware.Authenticate(), middleware.RequireTwoFactor(), middleware.RequirePermission(rbac.ManageCatalog))
+	admin.POST("/publisher", controller.AddPublisher())
+	admin.PATCH("/publisher/:publisher_id", controller.UpdatePublisher())
+	admin.DELETE("/publisher/:publisher_id", controller.DeletePublisher())
+	admin.POST("/publishers/merge", controller.MergePublishers())
+}
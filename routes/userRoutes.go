@@ -2,16 +2,38 @@ package routes
 
 import (
 	controller "github.com/SHUBHAM91285/online_book_store/controllers"
+	"github.com/SHUBHAM91285/online_book_store/middleware"
+	"github.com/SHUBHAM91285/online_book_store/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
 func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("/user/signup", controller.SignUp())
-	incomingRoutes.POST("/user/login", controller.Login())
-	incomingRoutes.GET("/user/profile", controller.UserProfile())
-	incomingRoutes.PATCH("/user/profile/password", controller.UpdatePassword())
-	incomingRoutes.PATCH("/cart/add", controller.AddBookToCart())
-	incomingRoutes.PATCH("/cart/update/:id", controller.UpdateBookQuantity())
-	incomingRoutes.PATCH("/cart/remove/:id", controller.RemoveBookFromCart())
+	public := incomingRoutes.Group("/")
+	public.POST("/user/signup", controller.SignUp())
+	public.POST("/user/login", controller.Login())
+	public.POST("/user/login/2fa", controller.LoginTwoFactor())
+	public.POST("/user/token/refresh", controller.RefreshToken())
+	public.GET("/user/password/reset", controller.PasswordResetForm())
+	public.POST("/user/password/reset/request", controller.RequestPasswordReset())
+	public.POST("/user/password/reset/confirm", controller.ConfirmPasswordReset())
+	public.GET("/user/verify", controller.VerifyEmail())
+	public.POST("/user/verify/resend", controller.ResendVerificationEmail())
+
+	authenticated := incomingRoutes.Group("/", middleware.Authenticate())
+	authenticated.GET("/user/profile", controller.UserProfile())
+	authenticated.PATCH("/user/profile/password", controller.UpdatePassword())
+	authenticated.POST("/user/logout", controller.Logout())
+	authenticated.POST("/user/logout/all", controller.LogoutAllSessions())
+	authenticated.POST("/user/2fa/enroll", controller.EnrollTwoFactor())
+	authenticated.POST("/user/2fa/activate", controller.ActivateTwoFactor())
+	authenticated.POST("/user/2fa/disable", controller.DisableTwoFactor())
+	authenticated.PATCH("/cart/add", controller.AddBookToCart())
+	authenticated.PATCH("/cart/update/:id", controller.UpdateBookQuantity())
+	authenticated.PATCH("/cart/remove/:id", controller.RemoveBookFromCart())
+
+	admin := incomingRoutes.Group("/admin", middleware.Authenticate(), middleware.RequireTwoFactor(), middleware.RequirePermission(rbac.ManageUsers))
+	admin.GET("/roles", controller.ListRoles())
+	admin.PUT("/user/:user_id/roles", controller.AssignRoles())
+	admin.POST("/user/:user_id/unlock", controller.UnlockAccount())
 }
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	controller "github.com/SHUBHAM91285/online_book_store/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WellKnownRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/.well-known/jwks.json", controller.JWKS())
+}
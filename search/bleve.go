@@ -0,0 +1,90 @@
+package search
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BleveIndex keeps an embedded index of the books, for deployments whose
+// Mongo cannot serve text search. It must be filled from the books
+// collection at startup and kept in sync through Index and Delete.
+type BleveIndex struct {
+	index bleve.Index
+}
+
+type bleveDocument struct {
+	Name        string `json:"name"`
+	Author_name string `json:"author_name"`
+	Genre       string `json:"genre"`
+	Author_info string `json:"author_info"`
+	Description string `json:"description"`
+}
+
+// NewBleveIndex opens the index stored at path, creating it if needed. An
+// empty path keeps the index in memory.
+func NewBleveIndex(path string) (*BleveIndex, error) {
+	mapping := bleve.NewIndexMapping()
+	mapping.DefaultAnalyzer = en.AnalyzerName
+
+	if path == "" {
+		index, err := bleve.NewMemOnly(mapping)
+		if err != nil {
+			return nil, err
+		}
+		return &BleveIndex{index: index}, nil
+	}
+
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, mapping)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &BleveIndex{index: index}, nil
+}
+
+func (s *BleveIndex) Index(ctx context.Context, book models.Books) error {
+	return s.index.Index(book.ID.Hex(), bleveDocument{
+		Name:        book.Name,
+		Author_name: book.Author_name,
+		Genre:       book.Genre,
+		Author_info: book.Author_info,
+		Description: book.Description,
+	})
+}
+
+func (s *BleveIndex) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return s.index.Delete(id.Hex())
+}
+
+func (s *BleveIndex) Search(ctx context.Context, text string, limit int) ([]Hit, error) {
+	var fields []query.Query
+	for _, w := range Weights {
+		match := bleve.NewMatchQuery(text)
+		match.SetField(w.Field)
+		match.SetBoost(float64(w.Weight))
+		fields = append(fields, match)
+	}
+	request := bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(fields...), limit, 0, false)
+	result, err := s.index.SearchInContext(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(result.Hits))
+	for _, match := range result.Hits {
+		id, err := primitive.ObjectIDFromHex(match.ID)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, Hit{ID: id, Score: match.Score})
+	}
+	return hits, nil
+}
@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoIndex searches the books collection through a Mongo text index, which
+// the server keeps up to date by itself.
+type MongoIndex struct {
+	collection *mongo.Collection
+}
+
+func NewMongoIndex(collection *mongo.Collection) *MongoIndex {
+	return &MongoIndex{collection: collection}
+}
+
+// EnsureIndexes creates the weighted text index. A collection can only have
+// one text index.
+func (s *MongoIndex) EnsureIndexes(ctx context.Context) error {
+	keys := bson.D{}
+	weights := bson.D{}
+	for _, w := range Weights {
+		keys = append(keys, bson.E{Key: w.Field, Value: "text"})
+		weights = append(weights, bson.E{Key: w.Field, Value: w.Weight})
+	}
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetName("books_text").SetWeights(weights),
+	})
+	return err
+}
+
+func (s *MongoIndex) Index(ctx context.Context, book models.Books) error {
+	return nil
+}
+
+func (s *MongoIndex) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return nil
+}
+
+func (s *MongoIndex) Search(ctx context.Context, query string, limit int) ([]Hit, error) {
+	score := bson.M{"$meta": "textScore"}
+	cursor, err := s.collection.Find(ctx,
+		bson.M{"$text": bson.M{"$search": query}},
+		options.Find().
+			SetProjection(bson.M{"_id": 1, "score": score}).
+			SetSort(bson.M{"score": score}).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Score float64            `bson:"score"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	hits := make([]Hit, len(results))
+	for i, result := range results {
+		hits[i] = Hit{ID: result.ID, Score: result.Score}
+	}
+	return hits, nil
+}
@@ -0,0 +1,40 @@
+// Package search ranks books against free-text queries.
+package search
+
+import (
+	"context"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Weights gives how much a match in each book field counts towards a hit's
+// score, so that title matches rank above description matches.
+var Weights = []struct {
+	Field  string
+	Weight int
+}{
+	{"name", 10},
+	{"author_name", 5},
+	{"genre", 3},
+	{"author_info", 2},
+	{"description", 1},
+}
+
+// Hit is a book matching a query, with its relevance score.
+type Hit struct {
+	ID    primitive.ObjectID
+	Score float64
+}
+
+// SearchIndex is a full-text index over the catalogue. Implementations that
+// keep their own copy of the books must be told about every change through
+// Index and Delete.
+type SearchIndex interface {
+	// Index adds book to the index, replacing any earlier version.
+	Index(ctx context.Context, book models.Books) error
+	// Delete removes the book with id from the index.
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// Search returns at most limit hits for query, best first.
+	Search(ctx context.Context, query string, limit int) ([]Hit, error)
+}
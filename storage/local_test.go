@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStorePath(t *testing.T) {
+	s := NewLocalStore(filepath.Join("var", "uploads"), "http://localhost:8000/uploads/")
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"covers/1.jpg", filepath.Join("var", "uploads", "covers", "1.jpg"), false},
+		{"cover.png", filepath.Join("var", "uploads", "cover.png"), false},
+		{"covers/a..b.jpg", filepath.Join("var", "uploads", "covers", "a..b.jpg"), false},
+		{"", "", true},
+		{"../x", "", true},
+		{"covers/../../x", "", true},
+		{"covers/../x", "", true},
+		{"/etc/passwd", "", true},
+		{"covers//1.jpg", "", true},
+		{"covers/./1.jpg", "", true},
+		{"covers/", "", true},
+		{".", "", true},
+		{"..", "", true},
+	}
+	for _, tt := range tests {
+		got, err := s.path(tt.key)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("path(%q) = %q, %v, want %v", tt.key, got, err, ErrInvalidKey)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("path(%q) = %q, %v, want %q", tt.key, got, err, tt.want)
+		}
+	}
+}
+
+func TestLocalStoreURL(t *testing.T) {
+	s := NewLocalStore("uploads", "http://localhost:8000/uploads/")
+	if got, want := s.URL("covers/1.jpg"), "http://localhost:8000/uploads/covers/1.jpg"; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+}
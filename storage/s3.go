@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config locates a bucket on S3 or a compatible server such as MinIO.
+type S3Config struct {
+	Endpoint   string
+	Access_key string
+	Secret_key string
+	Bucket     string
+	Use_ssl    bool
+	// Public_url is where the bucket's objects are served from, such as a
+	// CDN. It defaults to the bucket's URL on Endpoint.
+	Public_url string
+}
+
+// S3Store keeps blobs as objects in an S3 bucket. Objects are expected to
+// be publicly readable, through the bucket policy or a CDN in front of it.
+type S3Store struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+func NewS3Store(config S3Config) (*S3Store, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.Access_key, config.Secret_key, ""),
+		Secure: config.Use_ssl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	publicURL := config.Public_url
+	if publicURL == "" {
+		scheme := "http://"
+		if config.Use_ssl {
+			scheme = "https://"
+		}
+		publicURL = scheme + config.Endpoint + "/" + config.Bucket
+	}
+	return &S3Store{client: client, bucket: config.Bucket, publicURL: strings.TrimSuffix(publicURL, "/")}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		// Keys are never reused for different content.
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	return err
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3Store) URL(key string) string {
+	return s.publicURL + "/" + key
+}
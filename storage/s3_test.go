@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 answers the requests S3Store makes, keeping objects in memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+	headers map[string]http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if _, ok := r.URL.Query()["location"]; ok {
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, err := readPayload(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+		f.headers[r.URL.Path] = r.Header.Clone()
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// readPayload returns the object in a PUT, undoing the signed chunks
+// clients send over plain HTTP.
+func readPayload(r *http.Request) (string, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		body, err := io.ReadAll(r.Body)
+		return string(body), err
+	}
+	reader := bufio.NewReader(r.Body)
+	var payload strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return "", err
+		}
+		if size == 0 {
+			return payload.String(), nil
+		}
+		if _, err := io.CopyN(&payload, reader, size); err != nil {
+			return "", err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return "", err
+		}
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: map[string]string{}, headers: map[string]http.Header{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:   strings.TrimPrefix(server.URL, "http://"),
+		Access_key: "access",
+		Secret_key: "secret",
+		Bucket:     "books",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	body := "not really a jpeg"
+	if err := store.Put(ctx, "covers/1/original.jpg", strings.NewReader(body), int64(len(body)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	const path = "/books/covers/1/original.jpg"
+	if got := fake.objects[path]; got != body {
+		t.Fatalf("stored %q at %s, want %q", got, path, body)
+	}
+	header := fake.headers[path]
+	if got := header.Get("Content-Type"); got != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want image/jpeg", got)
+	}
+	if got := header.Get("Cache-Control"); !strings.Contains(got, "immutable") {
+		t.Errorf("Cache-Control = %q, want the object cached for good", got)
+	}
+
+	if got, want := store.URL("covers/1/original.jpg"), server.URL+path; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+
+	if err := store.Delete(ctx, "covers/1/original.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects[path]; ok {
+		t.Error("Delete left the object behind")
+	}
+}
+
+func TestS3StorePublicURL(t *testing.T) {
+	tests := []struct {
+		config S3Config
+		want   string
+	}{
+		{S3Config{Endpoint: "s3.example.com", Bucket: "books"}, "http://s3.example.com/books/covers/1.jpg"},
+		{S3Config{Endpoint: "s3.example.com", Bucket: "books", Use_ssl: true}, "https://s3.example.com/books/covers/1.jpg"},
+		{S3Config{Endpoint: "s3.example.com", Bucket: "books", Public_url: "https://cdn.example.com/"}, "https://cdn.example.com/covers/1.jpg"},
+	}
+	for _, tt := range tests {
+		store, err := NewS3Store(tt.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := store.URL("covers/1.jpg"); got != tt.want {
+			t.Errorf("URL with %+v = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+// Package storage keeps uploaded files such as book covers.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore stores blobs under slash-separated keys and serves them from
+// public URLs.
+type BlobStore interface {
+	// Put stores size bytes from r under key, replacing any blob there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients can fetch the blob under key.
+	URL(key string) string
+}
@@ -0,0 +1,223 @@
+// Package suggest completes and corrects what customers type into the
+// search box, from an in-memory trie of titles, authors and genres.
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Kind says what a suggested term is.
+type Kind string
+
+const (
+	Title  Kind = "title"
+	Author Kind = "author"
+	Genre  Kind = "genre"
+)
+
+// MaxCompletions is the most completions Complete returns for a prefix.
+const MaxCompletions = 10
+
+// Entry is a term to suggest.
+type Entry struct {
+	Text string
+	Kind Kind
+}
+
+// Suggestion is a term matching what was typed. Weight counts the books
+// carrying the term.
+type Suggestion struct {
+	Text   string `json:"text"`
+	Kind   Kind   `json:"kind"`
+	Weight int    `json:"-"`
+}
+
+// Trie holds terms by their lower-cased text. It is built once by Build and
+// only read afterwards, so it is safe for concurrent use.
+type Trie struct {
+	root *node
+}
+
+type node struct {
+	children map[rune]*node
+	terms    []Suggestion
+	// top holds the best completions in this subtree, most common first.
+	top []Suggestion
+}
+
+// Build makes a trie of entries. Repeated entries raise the weight of the
+// term instead of being added twice.
+func Build(entries []Entry) *Trie {
+	root := &node{}
+	for _, entry := range entries {
+		text := strings.TrimSpace(entry.Text)
+		if text == "" {
+			continue
+		}
+		n := root
+		for _, r := range strings.ToLower(text) {
+			child, ok := n.children[r]
+			if !ok {
+				if n.children == nil {
+					n.children = map[rune]*node{}
+				}
+				child = &node{}
+				n.children[r] = child
+			}
+			n = child
+		}
+		n.add(Suggestion{Text: text, Kind: entry.Kind, Weight: 1})
+	}
+	root.rank()
+	return &Trie{root: root}
+}
+
+func (n *node) add(s Suggestion) {
+	for i, term := range n.terms {
+		if term.Kind == s.Kind && strings.EqualFold(term.Text, s.Text) {
+			n.terms[i].Weight++
+			return
+		}
+	}
+	n.terms = append(n.terms, s)
+}
+
+// rank fills top for n and every node below it.
+func (n *node) rank() {
+	candidates := append([]Suggestion(nil), n.terms...)
+	for _, child := range n.children {
+		child.rank()
+		candidates = append(candidates, child.top...)
+	}
+	sortSuggestions(candidates)
+	n.top = candidates[:min(len(candidates), MaxCompletions)]
+}
+
+func sortSuggestions(suggestions []Suggestion) {
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Weight != suggestions[j].Weight {
+			return suggestions[i].Weight > suggestions[j].Weight
+		}
+		return suggestions[i].Text < suggestions[j].Text
+	})
+}
+
+// Complete returns up to limit terms starting with prefix, ignoring case,
+// the most common first. limit is capped at MaxCompletions.
+func (t *Trie) Complete(prefix string, limit int) []Suggestion {
+	n := t.root
+	for _, r := range strings.ToLower(strings.TrimLeft(prefix, " ")) {
+		n = n.children[r]
+		if n == nil {
+			return nil
+		}
+	}
+	return append([]Suggestion(nil), n.top[:min(len(n.top), limit)]...)
+}
+
+// Correct returns up to limit terms within maxDistance edits of text,
+// ignoring case, closest and then most common first.
+func (t *Trie) Correct(text string, maxDistance, limit int) []Suggestion {
+	target := []rune(strings.ToLower(strings.TrimSpace(text)))
+	row := make([]int, len(target)+1)
+	for i := range row {
+		row[i] = i
+	}
+
+	var found []scored
+	for r, child := range t.root.children {
+		child.correct(r, target, row, maxDistance, &found)
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].distance != found[j].distance {
+			return found[i].distance < found[j].distance
+		}
+		if found[i].Weight != found[j].Weight {
+			return found[i].Weight > found[j].Weight
+		}
+		return found[i].Text < found[j].Text
+	})
+
+	suggestions := make([]Suggestion, 0, min(len(found), limit))
+	for _, s := range found[:min(len(found), limit)] {
+		suggestions = append(suggestions, s.Suggestion)
+	}
+	return suggestions
+}
+
+type scored struct {
+	Suggestion
+	distance int
+}
+
+// correct walks the trie computing one row of the Levenshtein matrix per
+// node, and stops descending once every entry of a row exceeds
+// maxDistance.
+func (n *node) correct(r rune, target []rune, previous []int, maxDistance int, found *[]scored) {
+	row := make([]int, len(previous))
+	row[0] = previous[0] + 1
+	best := row[0]
+	for i := 1; i < len(row); i++ {
+		substitution := previous[i-1]
+		if target[i-1] != r {
+			substitution++
+		}
+		row[i] = min(row[i-1]+1, previous[i]+1, substitution)
+		best = min(best, row[i])
+	}
+
+	if distance := row[len(row)-1]; distance <= maxDistance {
+		for _, term := range n.terms {
+			*found = append(*found, scored{Suggestion: term, distance: distance})
+		}
+	}
+	if best > maxDistance {
+		return
+	}
+	for next, child := range n.children {
+		child.correct(next, target, row, maxDistance, found)
+	}
+}
+
+// MaxDistance is the number of typos tolerated in text: one for short
+// words, growing with the length of the text.
+func MaxDistance(text string) int {
+	switch length := utf8.RuneCountInString(text); {
+	case length <= 4:
+		return 1
+	case length <= 10:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Index serves suggestions from a trie that can be swapped for a fresher
+// one while in use. The zero Index suggests nothing.
+type Index struct {
+	trie atomic.Pointer[Trie]
+}
+
+// Replace makes i serve suggestions from t.
+func (i *Index) Replace(t *Trie) {
+	i.trie.Store(t)
+}
+
+func (i *Index) Complete(prefix string, limit int) []Suggestion {
+	t := i.trie.Load()
+	if t == nil {
+		return nil
+	}
+	return t.Complete(prefix, limit)
+}
+
+func (i *Index) Correct(text string, maxDistance, limit int) []Suggestion {
+	t := i.trie.Load()
+	if t == nil {
+		return nil
+	}
+	return t.Correct(text, maxDistance, limit)
+}
@@ -0,0 +1,161 @@
+package suggest
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testEntries = []Entry{
+	{"The Hobbit", Title},
+	{"The Hobbit", Title},
+	{"the hobbit", Title},
+	{"The Silmarillion", Title},
+	{"Harry Potter", Title},
+	{"J. R. R. Tolkien", Author},
+	{"J. R. R. Tolkien", Author},
+	{"J. K. Rowling", Author},
+	{"Fantasy", Genre},
+	{"Fantasy", Genre},
+	{"Fantasy", Genre},
+	{"Fiction", Genre},
+	{"  ", Title},
+}
+
+func texts(suggestions []Suggestion) []string {
+	got := []string{}
+	for _, s := range suggestions {
+		got = append(got, s.Text)
+	}
+	return got
+}
+
+func TestComplete(t *testing.T) {
+	trie := Build(testEntries)
+	tests := []struct {
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{"the", 10, []string{"The Hobbit", "The Silmarillion"}},
+		{"THE H", 10, []string{"The Hobbit"}},
+		{"  the s", 10, []string{"The Silmarillion"}},
+		{"f", 10, []string{"Fantasy", "Fiction"}},
+		{"j. ", 10, []string{"J. R. R. Tolkien", "J. K. Rowling"}},
+		{"j. ", 1, []string{"J. R. R. Tolkien"}},
+		{"h", 10, []string{"Harry Potter"}},
+		{"x", 10, []string{}},
+		{"the hobbits", 10, []string{}},
+	}
+	for _, tt := range tests {
+		got := texts(trie.Complete(tt.prefix, tt.limit))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q, %d) = %q, want %q", tt.prefix, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestBuildMergesRepeatedEntries(t *testing.T) {
+	trie := Build(testEntries)
+	got := trie.Complete("the hobbit", 10)
+	want := []Suggestion{{Text: "The Hobbit", Kind: Title, Weight: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Complete(%q) = %+v, want %+v", "the hobbit", got, want)
+	}
+
+	// The same text under another kind is a separate term.
+	trie = Build([]Entry{{"Dune", Title}, {"Dune", Genre}})
+	if got := trie.Complete("dune", 10); len(got) != 2 {
+		t.Errorf("Complete(%q) = %+v, want a title and a genre", "dune", got)
+	}
+}
+
+func TestCompleteIsCapped(t *testing.T) {
+	var entries []Entry
+	for c := 'a'; c <= 'z'; c++ {
+		entries = append(entries, Entry{"book " + string(c), Title})
+	}
+	trie := Build(entries)
+	if got := trie.Complete("book", 100); len(got) != MaxCompletions {
+		t.Errorf("Complete returned %d suggestions, want %d", len(got), MaxCompletions)
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	trie := Build(testEntries)
+	tests := []struct {
+		text        string
+		maxDistance int
+		limit       int
+		want        []string
+	}{
+		{"fantasy", 1, 10, []string{"Fantasy"}},
+		{"fantsy", 1, 10, []string{"Fantasy"}},
+		{"FANTASI", 1, 10, []string{"Fantasy"}},
+		{"the hobit", 1, 10, []string{"The Hobbit"}},
+		{"fictoin", 2, 10, []string{"Fiction"}},
+		{"fictoin", 1, 10, []string{}},
+		{"zzzzzz", 2, 10, []string{}},
+	}
+	for _, tt := range tests {
+		got := texts(trie.Correct(tt.text, tt.maxDistance, tt.limit))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Correct(%q, %d, %d) = %q, want %q", tt.text, tt.maxDistance, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestCorrectOrder(t *testing.T) {
+	trie := Build([]Entry{{"cart", Title}, {"cut", Title}, {"cut", Title}, {"cat", Genre}, {"dog", Title}})
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{10, []string{"cat", "cut", "cart"}},
+		{2, []string{"cat", "cut"}},
+		{0, []string{}},
+	}
+	for _, tt := range tests {
+		got := texts(trie.Correct("cat", 1, tt.limit))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Correct(%q, 1, %d) = %q, want %q", "cat", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestMaxDistance(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 1},
+		{"dune", 1},
+		{"hobbit", 2},
+		{"tolkiensss", 2},
+		{"silmarillion", 3},
+		{"ñandúñandú", 2},
+	}
+	for _, tt := range tests {
+		if got := MaxDistance(tt.text); got != tt.want {
+			t.Errorf("MaxDistance(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	var index Index
+	if got := index.Complete("the", 10); got != nil {
+		t.Errorf("zero Index completed %+v", got)
+	}
+	if got := index.Correct("the", 1, 10); got != nil {
+		t.Errorf("zero Index corrected to %+v", got)
+	}
+
+	index.Replace(Build(testEntries))
+	if got := texts(index.Complete("harry", 10)); !reflect.DeepEqual(got, []string{"Harry Potter"}) {
+		t.Errorf("Complete(%q) = %q after Replace", "harry", got)
+	}
+	index.Replace(Build([]Entry{{"Harrow", Title}}))
+	if got := texts(index.Complete("harr", 10)); !reflect.DeepEqual(got, []string{"Harrow"}) {
+		t.Errorf("Complete(%q) = %q after a second Replace", "harr", got)
+	}
+}
@@ -0,0 +1,75 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	PasswordResetPurpose     = "password_reset"
+	EmailVerificationPurpose = "email_verification"
+)
+
+var ErrInvalidActionToken = errors.New("token is invalid, expired or already used")
+
+var actionTokensCollection *mongo.Collection = database.OpenCollection(database.Client, "action_tokens")
+
+// IssueActionToken creates a single-use token for purpose that expires after
+// ttl. Any earlier unused token for the same purpose and email stops working.
+func IssueActionToken(ctx context.Context, purpose, email string, ttl time.Duration) (string, error) {
+	rawToken, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().Local()
+
+	_, err = actionTokensCollection.UpdateMany(ctx,
+		bson.M{"purpose": purpose, "email": email, "used_at": nil},
+		bson.M{"$set": bson.M{"used_at": now}},
+	)
+	if err != nil {
+		return "", err
+	}
+	_, err = actionTokensCollection.InsertOne(ctx, models.ActionToken{
+		ID:         primitive.NewObjectID(),
+		Token_hash: hashToken(rawToken),
+		Purpose:    purpose,
+		Email:      email,
+		Expires_at: now.Add(ttl),
+		Created_at: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+// ConsumeActionToken marks the token as used and returns the email it was
+// issued for. A token can only be consumed once.
+func ConsumeActionToken(ctx context.Context, purpose, rawToken string) (string, error) {
+	now := time.Now().Local()
+	var found models.ActionToken
+	err := actionTokensCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"token_hash": hashToken(rawToken),
+			"purpose":    purpose,
+			"used_at":    nil,
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+	).Decode(&found)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrInvalidActionToken
+	}
+	if err != nil {
+		return "", err
+	}
+	return found.Email, nil
+}
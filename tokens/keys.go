@@ -0,0 +1,282 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// Signing keys live as PEM encoded private keys (PKCS#8, or PKCS#1 for RSA)
+// in JWT_KEYS_DIR. The file name without ".pem" is the key ID ("kid") and
+// the modification time is when the key was created.
+//
+// Every key in the directory is accepted for verification and published in
+// the JWKS document. New tokens are signed with the newest key, but only
+// once it has been published for keyPropagationDelay, so other services
+// have a chance to fetch it first.
+//
+// When JWT_KEY_ROTATION is set (for example "720h"), a new key using
+// JWT_ALGORITHM (RS256 or EdDSA, default RS256) is generated whenever the
+// newest key is older than that, and keys that can no longer have valid
+// tokens are removed. Only one instance should run with rotation enabled;
+// the others pick up new keys on their next reload.
+const (
+	keyReloadInterval   = time.Minute
+	keyPropagationDelay = 2 * keyReloadInterval
+)
+
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	created time.Time
+}
+
+type keyRing struct {
+	mu       sync.RWMutex
+	dir      string
+	rotation time.Duration
+	method   jwt.SigningMethod
+	keys     []*signingKey // sorted by creation time, oldest first
+}
+
+var keys = &keyRing{}
+
+// LoadKeys reads the signing keys from JWT_KEYS_DIR. The server must not
+// start when it fails, since it could neither issue nor verify tokens.
+func LoadKeys() error {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		return errors.New("JWT_KEYS_DIR must point to a directory with signing keys")
+	}
+
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	switch alg := os.Getenv("JWT_ALGORITHM"); alg {
+	case "", "RS256":
+	case "EdDSA":
+		method = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q, use RS256 or EdDSA", alg)
+	}
+
+	var rotation time.Duration
+	if value := os.Getenv("JWT_KEY_ROTATION"); value != "" {
+		var err error
+		rotation, err = time.ParseDuration(value)
+		if err != nil || rotation <= 0 {
+			return fmt.Errorf("invalid JWT_KEY_ROTATION %q", value)
+		}
+	}
+
+	keys.mu.Lock()
+	keys.dir, keys.method, keys.rotation = dir, method, rotation
+	keys.mu.Unlock()
+
+	if err := keys.reload(); err != nil {
+		return err
+	}
+	if rotation > 0 {
+		if err := keys.rotate(); err != nil {
+			return err
+		}
+	}
+	if keys.signing() == nil {
+		return fmt.Errorf("no signing keys found in %s", dir)
+	}
+	return nil
+}
+
+// StartKeyRotation periodically reloads the key directory and, when
+// rotation is enabled, generates and retires keys. It runs until the
+// process exits.
+func StartKeyRotation() {
+	go func() {
+		for range time.Tick(keyReloadInterval) {
+			if err := keys.reload(); err != nil {
+				log.Println("failed to reload signing keys:", err)
+				continue
+			}
+			if err := keys.rotate(); err != nil {
+				log.Println("failed to rotate signing keys:", err)
+			}
+		}
+	}()
+}
+
+// signing returns the key new tokens are signed with.
+func (r *keyRing) signing() *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.keys) == 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-keyPropagationDelay)
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if !r.keys[i].created.After(cutoff) {
+			return r.keys[i]
+		}
+	}
+	// Nothing has been published long enough, which only happens right
+	// after the first key was created.
+	return r.keys[len(r.keys)-1]
+}
+
+func (r *keyRing) lookup(kid string) *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.kid == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+func (r *keyRing) all() []*signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*signingKey(nil), r.keys...)
+}
+
+func (r *keyRing) reload() error {
+	r.mu.RLock()
+	dir := r.dir
+	r.mu.RUnlock()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	var loaded []*signingKey
+	for _, path := range paths {
+		key, err := readKey(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		loaded = append(loaded, key)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].created.Before(loaded[j].created) })
+
+	r.mu.Lock()
+	r.keys = loaded
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *keyRing) rotate() error {
+	r.mu.RLock()
+	rotation, dir, method := r.rotation, r.dir, r.method
+	current := append([]*signingKey(nil), r.keys...)
+	r.mu.RUnlock()
+	if rotation == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if len(current) == 0 || now.Sub(current[len(current)-1].created) >= rotation {
+		if err := generateKey(dir, method, now); err != nil {
+			return err
+		}
+	}
+
+	// A key stops being used for signing once its successor is published,
+	// so after the longest token lifetime nothing it signed is valid.
+	maxTokenTTL := max(AccessTokenTTL, TwoFactorChallengeTTL)
+	for i := 0; i < len(current)-1; i++ {
+		retiredAt := current[i+1].created.Add(keyPropagationDelay)
+		if now.Sub(retiredAt) > maxTokenTTL {
+			path := filepath.Join(dir, current[i].kid+".pem")
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return r.reload()
+}
+
+func generateKey(dir string, method jwt.SigningMethod, now time.Time) error {
+	var private crypto.Signer
+	var err error
+	if method == jwt.SigningMethodEdDSA {
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+
+	kid := now.UTC().Format("20060102T150405Z") + "-" + newTokenID()[:8]
+	path := filepath.Join(dir, kid+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	// Write under a name the loader ignores and rename, so a concurrent
+	// reload never sees a half written key.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{
+		kid:     strings.TrimSuffix(filepath.Base(path), ".pem"),
+		created: info.ModTime(),
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		key.method, key.private = jwt.SigningMethodRS256, private
+	case ed25519.PrivateKey:
+		key.method, key.private = jwt.SigningMethodEdDSA, private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+	return key, nil
+}
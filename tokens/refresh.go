@@ -0,0 +1,124 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+var ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+
+var refreshTokensCollection *mongo.Collection = database.OpenCollection(database.Client, "refresh_tokens")
+
+// IssueRefreshToken starts a new session for email and returns the opaque
+// refresh token. Only its hash is stored.
+func IssueRefreshToken(ctx context.Context, email string) (string, error) {
+	return issueRefreshToken(ctx, email, newTokenID())
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same
+// session. Presenting a token that was already rotated means it leaked, so
+// the whole session is revoked.
+func RotateRefreshToken(ctx context.Context, rawToken string) (email string, newToken string, err error) {
+	var found models.RefreshToken
+	err = refreshTokensCollection.FindOneAndUpdate(ctx,
+		bson.M{"token_hash": hashToken(rawToken), "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	).Decode(&found)
+	if err == mongo.ErrNoDocuments {
+		if _, reuseErr := revokeFamilyOf(ctx, rawToken); reuseErr != nil {
+			return "", "", reuseErr
+		}
+		return "", "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if found.Expires_at.Before(time.Now()) {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	newToken, err = issueRefreshToken(ctx, found.Email, found.Family)
+	if err != nil {
+		return "", "", err
+	}
+	return found.Email, newToken, nil
+}
+
+// RevokeRefreshToken ends the session the refresh token belongs to.
+func RevokeRefreshToken(ctx context.Context, rawToken string) error {
+	found, err := revokeFamilyOf(ctx, rawToken)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+// revokeFamilyOf revokes every refresh token rotated from the same login as
+// rawToken. It reports whether rawToken was known at all.
+func revokeFamilyOf(ctx context.Context, rawToken string) (bool, error) {
+	var found models.RefreshToken
+	err := refreshTokensCollection.FindOne(ctx, bson.M{"token_hash": hashToken(rawToken)}).Decode(&found)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = refreshTokensCollection.UpdateMany(ctx,
+		bson.M{"family": found.Family},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	return true, err
+}
+
+func issueRefreshToken(ctx context.Context, email, family string) (string, error) {
+	rawToken, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().Local()
+	_, err = refreshTokensCollection.InsertOne(ctx, models.RefreshToken{
+		ID:         primitive.NewObjectID(),
+		Token_hash: hashToken(rawToken),
+		Email:      email,
+		Family:     family,
+		Expires_at: now.Add(RefreshTokenTTL),
+		Created_at: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+// newOpaqueToken returns a random URL-safe token. Opaque tokens are stored
+// hashed, so a database leak does not hand out working sessions.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(rawToken string) string {
+	sum := sha256.Sum256([]byte(rawToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func refreshTokenDoc(family string, expiresAt time.Time) bson.D {
+	return bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "token_hash", Value: hashToken("old")},
+		{Key: "email", Value: "alice@example.com"},
+		{Key: "family", Value: family},
+		{Key: "expires_at", Value: expiresAt},
+	}
+}
+
+var okResponse = mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+
+func TestRotateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name      string
+		responses []bson.D
+		wantErr   error
+		// wantFamily is the family of the token issued, or of the session
+		// revoked when wantErr is set.
+		wantFamily string
+	}{
+		{
+			name: "rotated",
+			responses: []bson.D{
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: refreshTokenDoc("session-1", time.Now().Add(time.Hour))}),
+				okResponse,
+			},
+			wantFamily: "session-1",
+		},
+		{
+			name: "expired",
+			responses: []bson.D{
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: refreshTokenDoc("session-1", time.Now().Add(-time.Hour))}),
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "reused after rotation revokes the session",
+			responses: []bson.D{
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+				mtest.CreateCursorResponse(0, "test.refresh_tokens", mtest.FirstBatch, refreshTokenDoc("session-1", time.Now().Add(time.Hour))),
+				okResponse,
+			},
+			wantErr:    ErrInvalidRefreshToken,
+			wantFamily: "session-1",
+		},
+		{
+			name: "unknown",
+			responses: []bson.D{
+				mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+				mtest.CreateCursorResponse(0, "test.refresh_tokens", mtest.FirstBatch),
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			refreshTokensCollection = mt.Coll
+			mt.AddMockResponses(tt.responses...)
+
+			email, newToken, err := RotateRefreshToken(ctx, "old")
+			if !errors.Is(err, tt.wantErr) {
+				mt.Fatalf("RotateRefreshToken error = %v, want %v", err, tt.wantErr)
+			}
+
+			events := mt.GetAllStartedEvents()
+			var last struct {
+				Updates []struct {
+					Q struct{ Family string }
+				}
+				Documents []models.RefreshToken
+			}
+			if err := bson.Unmarshal(events[len(events)-1].Command, &last); err != nil {
+				mt.Fatal(err)
+			}
+			switch {
+			case err == nil:
+				if email != "alice@example.com" || newToken == "" || newToken == "old" {
+					mt.Fatalf("RotateRefreshToken = %q, %q", email, newToken)
+				}
+				if len(last.Documents) != 1 || last.Documents[0].Family != tt.wantFamily || last.Documents[0].Token_hash != hashToken(newToken) {
+					mt.Errorf("inserted %+v, want the hash of the new token in family %s", last.Documents, tt.wantFamily)
+				}
+			case tt.wantFamily != "":
+				if len(last.Updates) != 1 || last.Updates[0].Q.Family != tt.wantFamily {
+					mt.Errorf("last update = %+v, want family %s revoked", last.Updates, tt.wantFamily)
+				}
+			}
+		})
+	}
+}
+
+func TestOpaqueTokens(t *testing.T) {
+	a, err := newOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newOpaqueToken()
+	if a == b || len(a) != 43 {
+		t.Errorf("newOpaqueToken returned %q and %q, want two different 43 character tokens", a, b)
+	}
+	if hashToken(a) != hashToken(a) || hashToken(a) == hashToken(b) || hashToken(a) == a {
+		t.Error("hashToken must be a deterministic hash that differs from its input")
+	}
+}
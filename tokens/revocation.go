@@ -0,0 +1,98 @@
+package tokens
+
+import (
+	"context"
+	"time"
+
+	"github.com/SHUBHAM91285/online_book_store/database"
+	"github.com/SHUBHAM91285/online_book_store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var revokedTokensCollection *mongo.Collection = database.OpenCollection(database.Client, "revoked_tokens")
+
+// RevokeAccessToken puts a single access token on the revocation list until
+// it would have expired anyway.
+func RevokeAccessToken(ctx context.Context, claims *SignedDetails) error {
+	_, err := revokedTokensCollection.InsertOne(ctx, models.RevokedToken{
+		ID:         primitive.NewObjectID(),
+		Jti:        claims.Id,
+		Expires_at: time.Unix(claims.ExpiresAt, 0),
+	})
+	return err
+}
+
+// RevokeAllSessions revokes every refresh token of the user and every access
+// token issued to them so far.
+func RevokeAllSessions(ctx context.Context, email string) error {
+	_, err := refreshTokensCollection.UpdateMany(ctx,
+		bson.M{"email": email, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Local()
+	_, err = revokedTokensCollection.UpdateOne(ctx,
+		bson.M{"email": email},
+		bson.M{
+			"$set":         bson.M{"revoked_before": now.UnixMicro(), "expires_at": now.Add(AccessTokenTTL)},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func isRevoked(claims *SignedDetails) (bool, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	issuedAt := claims.Issued_at_us
+	if issuedAt == 0 {
+		issuedAt = time.Unix(claims.IssuedAt, 0).UnixMicro()
+	}
+	count, err := revokedTokensCollection.CountDocuments(ctx, bson.M{
+		"$or": []bson.M{
+			{"jti": claims.Id},
+			{"email": claims.Email, "revoked_before": bson.M{"$gt": issuedAt}},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// EnsureIndexes creates the lookup and expiry indexes used by the token
+// collections. Mongo removes expired documents on its own through the TTL
+// indexes on expires_at.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := revokedTokensCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "jti", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = refreshTokensCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "family", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = actionTokensCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "purpose", Value: 1}, {Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
@@ -1,37 +1,94 @@
 package tokens
 
 import (
-	"os"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
+	jwt "github.com/golang-jwt/jwt/v4"
 )
 
 type SignedDetails struct {
 	Email string
+	// Purpose is empty for access tokens. Tokens minted for a narrower use,
+	// such as the second login step, name it here so they are never
+	// accepted as access tokens.
+	Purpose string `json:",omitempty"`
+	// Issued_at_us is the issue time in microseconds. The standard iat claim
+	// only has whole seconds, which cannot tell a token minted right after
+	// a revocation from one minted right before it.
+	Issued_at_us int64 `json:",omitempty"`
 	jwt.StandardClaims
 }
 
-var SECRET_KEY = os.Getenv("SECRET_KEY")
+// AccessTokenTTL is kept short because access tokens are only revocable
+// through the revocation list; refresh tokens carry the long-lived session.
+const AccessTokenTTL = 15 * time.Minute
+
+const (
+	TwoFactorChallengePurpose = "two_factor_challenge"
+	TwoFactorChallengeTTL     = 5 * time.Minute
+)
 
 func TokenGenerator(email string) (signedToken string, err error) {
+	return generate(email, "", AccessTokenTTL)
+}
+
+// ChallengeTokenGenerator issues the short-lived token returned by the first
+// login step of accounts with two-factor authentication.
+func ChallengeTokenGenerator(email string) (signedToken string, err error) {
+	return generate(email, TwoFactorChallengePurpose, TwoFactorChallengeTTL)
+}
+
+func VerifyToken(signedToken string) (claims *SignedDetails, msg string) {
+	return verify(signedToken, "")
+}
+
+func VerifyChallengeToken(signedToken string) (claims *SignedDetails, msg string) {
+	return verify(signedToken, TwoFactorChallengePurpose)
+}
+
+func generate(email, purpose string, ttl time.Duration) (signedToken string, err error) {
+	now := time.Now().Local()
 	claims := &SignedDetails{
-		Email: email,
+		Email:        email,
+		Purpose:      purpose,
+		Issued_at_us: now.UnixMicro(),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
+			Id:        newTokenID(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	key := keys.signing()
+	if key == nil {
+		return "", errors.New("no signing key loaded")
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	signedToken, err = token.SignedString(key.private)
 	if err != nil {
 		return "", err
 	}
-	return token, err
+	return signedToken, err
 }
 
-func VerifyToken(signedToken string) (claims *SignedDetails, msg string) {
+func verify(signedToken, purpose string) (claims *SignedDetails, msg string) {
 	token, err := jwt.ParseWithClaims(signedToken, &SignedDetails{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(SECRET_KEY), nil
+		kid, _ := token.Header["kid"].(string)
+		key := keys.lookup(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		// Only accept the algorithm the key was made for, so an RSA public
+		// key can never be abused as an HMAC secret.
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.private.Public(), nil
 	})
 	if err != nil {
 		msg = err.Error()
@@ -39,12 +96,28 @@ func VerifyToken(signedToken string) (claims *SignedDetails, msg string) {
 	}
 	claims, ok := token.Claims.(*SignedDetails)
 	if !ok {
-		msg = "the token is invalid"
-		return
+		return nil, "the token is invalid"
 	}
 	if claims.ExpiresAt < time.Now().Local().Unix() {
-		msg = "token is expired"
-		return
+		return nil, "token is expired"
+	}
+	if claims.Purpose != purpose {
+		return nil, "the token is invalid"
+	}
+	revoked, err := isRevoked(claims)
+	if err != nil {
+		return nil, "error occured while checking token revocation"
+	}
+	if revoked {
+		return nil, "token has been revoked"
 	}
 	return claims, msg
 }
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
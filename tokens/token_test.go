@@ -0,0 +1,175 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// useTestKey signs and verifies tokens with a fresh Ed25519 key for the rest
+// of the test.
+func useTestKey(t *testing.T) *signingKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &signingKey{kid: "test", method: jwt.SigningMethodEdDSA, private: private, created: time.Now().Add(-time.Hour)}
+	previous := keys
+	keys = &keyRing{keys: []*signingKey{key}}
+	t.Cleanup(func() { keys = previous })
+	return key
+}
+
+// countResponse answers the aggregate CountDocuments runs with n.
+func countResponse(n int) bson.D {
+	return mtest.CreateCursorResponse(0, "test.revoked_tokens", mtest.FirstBatch, bson.D{{Key: "n", Value: n}})
+}
+
+func TestVerifyRejects(t *testing.T) {
+	key := useTestKey(t)
+	sign := func(method jwt.SigningMethod, kid string, claims *SignedDetails, secret any) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	claims := func(purpose string, expiresIn time.Duration) *SignedDetails {
+		return &SignedDetails{
+			Email:          "alice@example.com",
+			Purpose:        purpose,
+			StandardClaims: jwt.StandardClaims{Id: newTokenID(), ExpiresAt: time.Now().Add(expiresIn).Unix()},
+		}
+	}
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	access, err := TokenGenerator("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := ChallengeTokenGenerator("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every one of these fails before the revocation list is consulted.
+	tests := []struct {
+		name   string
+		token  string
+		verify func(string) (*SignedDetails, string)
+	}{
+		{"challenge token as access token", challenge, VerifyToken},
+		{"access token as challenge token", access, VerifyChallengeToken},
+		{"expired", sign(key.method, key.kid, claims("", -time.Minute), key.private), VerifyToken},
+		{"unknown key", sign(key.method, "retired", claims("", time.Minute), key.private), VerifyToken},
+		{"signed by another key", sign(key.method, key.kid, claims("", time.Minute), otherKey), VerifyToken},
+		{"public key as HMAC secret", sign(jwt.SigningMethodHS256, key.kid, claims("", time.Minute), []byte(key.private.Public().(ed25519.PublicKey))), VerifyToken},
+		{"unsigned", sign(jwt.SigningMethodNone, key.kid, claims("", time.Minute), jwt.UnsafeAllowNoneSignatureType), VerifyToken},
+		{"tampered", access[:len(access)-4] + "AAAA", VerifyToken},
+		{"empty", "", VerifyToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, msg := tt.verify(tt.token)
+			if msg == "" || got != nil {
+				t.Errorf("verify accepted the token: %+v", got)
+			}
+		})
+	}
+}
+
+func TestVerifyRevocation(t *testing.T) {
+	useTestKey(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name    string
+		revoked int
+		wantMsg string
+	}{
+		{"valid", 0, ""},
+		{"revoked", 1, "token has been revoked"},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			revokedTokensCollection = mt.Coll
+			token, err := TokenGenerator("alice@example.com")
+			if err != nil {
+				mt.Fatal(err)
+			}
+			mt.AddMockResponses(countResponse(tt.revoked))
+			claims, msg := VerifyToken(token)
+			if msg != tt.wantMsg {
+				mt.Fatalf("VerifyToken message = %q, want %q", msg, tt.wantMsg)
+			}
+			if msg == "" && claims.Email != "alice@example.com" {
+				mt.Errorf("VerifyToken email = %q", claims.Email)
+			}
+		})
+	}
+}
+
+func TestIsRevokedComparesMicroseconds(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	issued := time.Date(2024, 5, 1, 12, 0, 0, 250_000_000, time.UTC)
+
+	tests := []struct {
+		name   string
+		claims *SignedDetails
+		// want is the issue time revoked_before is compared with.
+		want int64
+	}{
+		{
+			name: "microsecond claim",
+			claims: &SignedDetails{Email: "alice@example.com", Issued_at_us: issued.UnixMicro(),
+				StandardClaims: jwt.StandardClaims{Id: "jti-1", IssuedAt: issued.Unix()}},
+			want: issued.UnixMicro(),
+		},
+		{
+			name: "tokens issued before the claim existed",
+			claims: &SignedDetails{Email: "alice@example.com",
+				StandardClaims: jwt.StandardClaims{Id: "jti-2", IssuedAt: issued.Unix()}},
+			want: issued.Truncate(time.Second).UnixMicro(),
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			revokedTokensCollection = mt.Coll
+			mt.AddMockResponses(countResponse(0))
+			if _, err := isRevoked(tt.claims); err != nil {
+				mt.Fatal(err)
+			}
+
+			var command struct {
+				Pipeline []struct {
+					Match struct {
+						Or []struct {
+							Jti            string
+							Email          string
+							Revoked_before struct {
+								Gt int64 `bson:"$gt"`
+							}
+						} `bson:"$or"`
+					} `bson:"$match"`
+				}
+			}
+			if err := bson.Unmarshal(mt.GetStartedEvent().Command, &command); err != nil {
+				mt.Fatal(err)
+			}
+			or := command.Pipeline[0].Match.Or
+			if len(or) != 2 || or[0].Jti != tt.claims.Id || or[1].Email != tt.claims.Email {
+				mt.Fatalf("filter = %+v", or)
+			}
+			if got := or[1].Revoked_before.Gt; got != tt.want {
+				mt.Errorf("revoked_before compared with %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors,
+// "12345678901234567890", base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeSecretFormatting(t *testing.T) {
+	want, err := Code(rfcSecret, time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{
+		strings.ToLower(rfcSecret),
+		"GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ",
+		rfcSecret + "====",
+	} {
+		got, err := Code(secret, time.Unix(59, 0))
+		if err != nil || got != want {
+			t.Errorf("Code(%q) = %q, %v, want %q", secret, got, err, want)
+		}
+	}
+	if _, err := Code("not base32!", time.Unix(59, 0)); err == nil {
+		t.Error("Code accepted a secret that is not base32")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	codeAt := func(offset time.Duration) string {
+		code, err := Code(rfcSecret, now.Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	current := step(now)
+
+	tests := []struct {
+		name     string
+		secret   string
+		passcode string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current period", rfcSecret, codeAt(0), current, true},
+		{"previous period", rfcSecret, codeAt(-Period), current - 1, true},
+		{"next period", rfcSecret, codeAt(Period), current + 1, true},
+		{"two periods ago", rfcSecret, codeAt(-2 * Period), 0, false},
+		{"two periods ahead", rfcSecret, codeAt(2 * Period), 0, false},
+		{"wrong code", rfcSecret, "000000", 0, false},
+		{"too short", rfcSecret, codeAt(0)[:5], 0, false},
+		{"too long", rfcSecret, codeAt(0) + "0", 0, false},
+		{"invalid secret", "not base32!", codeAt(0), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := Validate(tt.secret, tt.passcode, now)
+			if gotStep != tt.wantStep || gotOK != tt.wantOK {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.passcode, gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		t.Fatalf("GenerateSecret returned %q, which does not decode: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Error("GenerateSecret returned the same secret twice")
+	}
+}